@@ -0,0 +1,194 @@
+package expectations
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientCertificate is TLS client certificate presented by client
+type ClientCertificate struct {
+	Subject    string
+	CommonName string
+	// SANs are DNS names, email addresses, IP addresses and URIs of certificate
+	SANs []string
+}
+
+// ClientCertMatcher is filter for TLS client certificate. SAN filter should pass for one of SANs
+type ClientCertMatcher struct {
+	Subject    StringMatcher `json:"subject"`
+	CommonName StringMatcher `json:"commonName"`
+	SAN        StringMatcher `json:"san"`
+}
+
+// trustedProxies are networks of proxies which X-Forwarded-For and X-Forwarded-Proto headers are trusted from
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets networks or addresses of proxies in front of gozzmock.
+// Forwarded headers of other clients are ignored, so client can't pretend to have another address
+func SetTrustedProxies(cidrs []string) error {
+	trimmed := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		trimmed = append(trimmed, strings.TrimSpace(cidr))
+	}
+	networks, err := parseCIDRs(trimmed)
+	if err != nil {
+		return err
+	}
+	trustedProxies = networks
+	return nil
+}
+
+// trustedProxy returns true if address belongs to one of trusted proxies
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns address of the direct peer
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns address of client. If request comes from trusted proxy,
+// the last address of X-Forwarded-For which isn't a trusted proxy is used
+func clientIP(r *http.Request) string {
+	addr := remoteIP(r)
+	if !trustedProxy(addr) {
+		return addr
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		forwardedAddr := strings.TrimSpace(forwarded[i])
+		if len(forwardedAddr) == 0 {
+			continue
+		}
+		addr = forwardedAddr
+		if !trustedProxy(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// clientCertificate returns the first certificate presented by client or nil
+func clientCertificate(r *http.Request) *ClientCertificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	clientCert := &ClientCertificate{
+		Subject:    cert.Subject.String(),
+		CommonName: cert.Subject.CommonName,
+	}
+	clientCert.SANs = append(clientCert.SANs, cert.DNSNames...)
+	clientCert.SANs = append(clientCert.SANs, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		clientCert.SANs = append(clientCert.SANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		clientCert.SANs = append(clientCert.SANs, uri.String())
+	}
+	return clientCert
+}
+
+// parseCIDRs parses CIDR filters. Single address is a network of this address only.
+// Returns the first error, invalid filter is skipped
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var firstErr error
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil {
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks, firstErr
+}
+
+// clientCIDRMatch validates whether the client address belongs to one of networks of the filter
+func clientCIDRMatch(ip string, exp *ExpectationRequest) bool {
+	if len(exp.ClientCIDR) == 0 {
+		return true
+	}
+
+	networks := exp.clientNetworks
+	if !exp.compiled {
+		networks, _ = parseCIDRs(exp.ClientCIDR)
+	}
+
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(clientIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// compile compiles matchers of certificate fields
+func (exp *ClientCertMatcher) compile() error {
+	var err error
+	for _, matcher := range []*StringMatcher{&exp.Subject, &exp.CommonName, &exp.SAN} {
+		if matcherErr := matcher.compile(); err == nil {
+			err = matcherErr
+		}
+	}
+	return err
+}
+
+// clientCertMatch validates whether the client certificate passes the filter. Returns name of failed field
+func clientCertMatch(cert *ClientCertificate, exp *ClientCertMatcher) (string, bool) {
+	if exp == nil {
+		return "", true
+	}
+	if cert == nil {
+		return "certificate", false
+	}
+
+	if !exp.Subject.Match(cert.Subject) {
+		return "subject", false
+	}
+	if !exp.CommonName.Match(cert.CommonName) {
+		return "commonName", false
+	}
+	if !exp.SAN.IsEmpty() && !(ValuesMatcher{exp.SAN}).Match(cert.SANs) {
+		return "san", false
+	}
+	return "", true
+}
+
+// clientCertSubject returns subject of certificate for logs
+func clientCertSubject(cert *ClientCertificate) string {
+	if cert == nil {
+		return "<none>"
+	}
+	return cert.Subject
+}
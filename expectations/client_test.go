@@ -0,0 +1,125 @@
+package expectations
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newClientCertRequestMust() *http.Request {
+	req := httpNewRequestMust("GET", "/", nil)
+	req.RemoteAddr = "10.0.1.15:53211"
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+		Subject:     pkix.Name{CommonName: "pipeline-a", Organization: []string{"Travix"}},
+		DNSNames:    []string{"agent-a.ci.local"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.1.15")},
+	}}}
+	return req
+}
+
+func TestHttpRequestToIncomingRequest_ClientAddressAndCertificate(t *testing.T) {
+	// Act
+	req, err := HttpRequestToIncomingRequest(newClientCertRequestMust())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.1.15", req.ClientIP)
+	assert.Equal(t, &ClientCertificate{
+		Subject:    "CN=pipeline-a,O=Travix",
+		CommonName: "pipeline-a",
+		SANs:       []string{"agent-a.ci.local", "10.0.1.15"},
+	}, req.ClientCert)
+}
+
+func withTrustedProxies(t *testing.T, cidrs ...string) func() {
+	previous := trustedProxies
+	if err := SetTrustedProxies(cidrs); err != nil {
+		t.Fatal(err)
+	}
+	return func() { trustedProxies = previous }
+}
+
+func TestClientIP_ForwardedForFromUntrustedClient_Ignored(t *testing.T) {
+	req := httpNewRequestMust("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "192.168.5.7")
+
+	assert.Equal(t, "10.0.0.1", clientIP(req))
+}
+
+func TestClientIP_ForwardedForFromTrustedProxy(t *testing.T) {
+	defer withTrustedProxies(t, "10.0.0.0/24", "10.0.1.1")()
+	req := httpNewRequestMust("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Add("X-Forwarded-For", "1.2.3.4, 192.168.5.7")
+	req.Header.Add("X-Forwarded-For", "10.0.1.1")
+
+	// Act
+	ip := clientIP(req)
+
+	// Assert
+	assert.Equal(t, "192.168.5.7", ip)
+}
+
+func TestParseCIDRs_SingleAddressAndInvalid(t *testing.T) {
+	networks, err := parseCIDRs([]string{"10.0.1.0/24", "192.168.5.7", "::1", "bad"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, len(networks))
+	assert.Equal(t, "192.168.5.7/32", networks[1].String())
+	assert.Equal(t, "::1/128", networks[2].String())
+}
+
+func TestClientCIDRMatch(t *testing.T) {
+	exp := &ExpectationRequest{ClientCIDR: []string{"10.0.1.0/24", "192.168.5.7"}}
+
+	assert.True(t, clientCIDRMatch("10.0.1.15", exp))
+	assert.True(t, clientCIDRMatch("192.168.5.7", exp))
+	assert.False(t, clientCIDRMatch("10.0.2.15", exp))
+	assert.False(t, clientCIDRMatch("not an ip", exp))
+	assert.True(t, clientCIDRMatch("10.0.2.15", &ExpectationRequest{}))
+}
+
+func TestClientCertMatch(t *testing.T) {
+	cert := &ClientCertificate{Subject: "CN=pipeline-a,O=Travix", CommonName: "pipeline-a",
+		SANs: []string{"agent-a.ci.local", "10.0.1.15"}}
+
+	_, ok := clientCertMatch(cert, &ClientCertMatcher{
+		CommonName: StringMatcher{Equals: "pipeline-a"},
+		SAN:        StringMatcher{Glob: "*.ci.local"}})
+	name, okSubject := clientCertMatch(cert, &ClientCertMatcher{Subject: StringMatcher{Contains: "O=Other"}})
+	nameNil, okNil := clientCertMatch(nil, &ClientCertMatcher{})
+
+	assert.True(t, ok)
+	assert.False(t, okSubject)
+	assert.Equal(t, "subject", name)
+	assert.False(t, okNil)
+	assert.Equal(t, "certificate", nameNil)
+}
+
+func TestGzFilter_Apply_ClientCIDRAndCertificate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "pipeline-a",
+		Request: &ExpectationRequest{
+			ClientCIDR: []string{"10.0.1.0/24"},
+			ClientCert: &ClientCertMatcher{CommonName: StringMatcher{Equals: "pipeline-a"}}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "a"},
+	})
+
+	other := httpNewRequestMust("GET", "/", nil)
+	other.RemoteAddr = "10.0.1.16:1234"
+
+	// Act
+	resp := filter.Apply(newClientCertRequestMust())
+	respOther := filter.Apply(other)
+
+	// Assert
+	assert.Equal(t, "a", string(resp.Body))
+	assert.Equal(t, http.StatusNotImplemented, respOther.HTTPCode)
+}
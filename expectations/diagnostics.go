@@ -0,0 +1,66 @@
+package expectations
+
+import (
+	"sort"
+	"strings"
+)
+
+// closestMatchesCount is number of closest expectations reported for unmatched request
+const closestMatchesCount = 3
+
+// closestMatch is an expectation which filter the request doesn't pass, with failed fields
+type closestMatch struct {
+	key        string
+	mismatches []mismatch
+}
+
+// closestMatches returns expectations with the least number of failed fields.
+// Expectations with the same number of failed fields are in priority order. JS predicates aren't run again
+func (f *GzFilter) closestMatches(req *IncomingRequest, count int) []closestMatch {
+	ordered := f.storage.GetOrdered()
+	var closest []closestMatch
+	for i := 0; i < len(ordered); i++ {
+		mismatches := requestMismatches(req, ordered[i].Request, allMismatches)
+		if len(mismatches) > 0 {
+			closest = append(closest, closestMatch{key: ordered[i].Key, mismatches: mismatches})
+		}
+	}
+
+	sort.SliceStable(closest, func(i, j int) bool {
+		return len(closest[i].mismatches) < len(closest[j].mismatches)
+	})
+	if len(closest) > count {
+		closest = closest[:count]
+	}
+	return closest
+}
+
+// closestMatchesToLog returns one line per closest expectation
+func closestMatchesToLog(closest []closestMatch) []string {
+	lines := make([]string, 0, len(closest))
+	for _, match := range closest {
+		reasons := make([]string, 0, len(match.mismatches))
+		for _, m := range match.mismatches {
+			reasons = append(reasons, m.String())
+		}
+		lines = append(lines, match.key+": "+strings.Join(reasons, "; "))
+	}
+	return lines
+}
+
+// closestMatchesToText returns human readable list of closest expectations for response body
+func closestMatchesToText(closest []closestMatch) string {
+	if len(closest) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\nClosest expectations:")
+	for _, match := range closest {
+		sb.WriteString("\n* " + match.key)
+		for _, m := range match.mismatches {
+			sb.WriteString("\n  - " + m.String())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,84 @@
+package expectations
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMismatches_AllFailedFields(t *testing.T) {
+	req := &IncomingRequest{Method: "GET", Path: "/users/1", URLPath: "/users/1", Body: "abc",
+		Headers: Headers{"Accept": {"text/plain"}}}
+	exp := &ExpectationRequest{
+		Method:  StringMatcher{Value: "POST"},
+		Path:    StringMatcher{Equals: "/users/1"},
+		Body:    StringMatcher{Contains: "xyz"},
+		Headers: ValuesMatchers{"Accept": {{Equals: "application/json"}}},
+	}
+
+	// Act
+	first := requestMismatches(req, exp, firstMismatch)
+	all := requestMismatches(req, exp, allMismatches)
+
+	// Assert
+	assert.Equal(t, 1, len(first))
+	assert.Equal(t, "method: request method GET != POST", first[0].String())
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, "body", all[1].field)
+	assert.Equal(t, "header Accept", all[2].field)
+	assert.Equal(t, `header Accept: request header [text/plain] doesn't match {"equals":"application/json"}`, all[2].String())
+}
+
+func TestRequestMismatches_Match_Empty(t *testing.T) {
+	assert.Empty(t, requestMismatches(&IncomingRequest{Method: "GET"}, &ExpectationRequest{Method: StringMatcher{Value: "GET"}}, allMismatches))
+	assert.Empty(t, requestMismatches(&IncomingRequest{Method: "GET"}, nil, allMismatches))
+}
+
+func TestRequestMismatches_AllMismatches_SkipsJsPredicate(t *testing.T) {
+	req := &IncomingRequest{Method: "GET"}
+	exp := &ExpectationRequest{Method: StringMatcher{Value: "POST"}, JsPredicate: jsBase64(`while (true) {}`),
+		AnyOf: []ExpectationRequest{{JsPredicate: jsBase64(`while (true) {}`)}}}
+	exp.compile()
+
+	// Act
+	mismatches := requestMismatches(req, exp, allMismatches)
+
+	// Assert
+	assert.Equal(t, 1, len(mismatches))
+	assert.Equal(t, "method", mismatches[0].field)
+}
+
+func TestShorten_LongBody(t *testing.T) {
+	assert.Equal(t, "abc", shorten("abc"))
+	assert.Equal(t, strings.Repeat("a", 200)+"...", shorten(strings.Repeat("a", 300)))
+}
+
+func TestGzFilter_Apply_NoMatchReportsClosest(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{Key: "typo", Priority: 2, Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "GET"}, Path: StringMatcher{Equals: "/userz"}}})
+	filter.Add(Expectation{Key: "far", Priority: 3, Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "POST"}, Path: StringMatcher{Equals: "/orders"}}})
+	filter.Add(Expectation{Key: "header", Priority: 1, Request: &ExpectationRequest{
+		Path: StringMatcher{Equals: "/users"}, Headers: ValuesMatchers{"X-Token": {{Equals: "t"}}}}})
+	filter.Add(Expectation{Key: "method", Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "DELETE"}}})
+	filter.Add(Expectation{Key: "last", Priority: -1, Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "PUT"}, Path: StringMatcher{Equals: "/"}}})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "/users", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusNotImplemented, resp.HTTPCode)
+	assert.Equal(t, `No expectations in gozzmock for request!
+Closest expectations:
+* typo
+  - path: request path /users doesn't match {"equals":"/userz"}
+* header
+  - header X-Token: request header [] doesn't match {"equals":"t"}
+* method
+  - method: request method GET != DELETE`, string(resp.Body))
+}
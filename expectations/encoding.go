@@ -0,0 +1,53 @@
+package expectations
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decodeBody decodes request body according to Content-Encoding header.
+// Several encodings are decoded in reverse order of applying
+func decodeBody(contentEncoding string, body []byte) ([]byte, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if len(encoding) == 0 || encoding == "identity" {
+			continue
+		}
+
+		reader, err := newDecodingReader(encoding, body)
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding %s body: %s", encoding, err.Error())
+		}
+	}
+	return body, nil
+}
+
+// newDecodingReader returns reader which decodes body of particular encoding
+func newDecodingReader(encoding string, body []byte) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// deflate is zlib format, but some clients send raw deflate stream
+		if reader, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			return reader, nil
+		}
+		return flate.NewReader(bytes.NewReader(body)), nil
+	case "br":
+		return brotli.NewReader(bytes.NewReader(body)), nil
+	}
+	return nil, fmt.Errorf("Unsupported content encoding %s", encoding)
+}
@@ -0,0 +1,112 @@
+package expectations
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+)
+
+func compressMust(encoding string, data string) []byte {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(&buf)
+	}
+	writer.Write([]byte(data))
+	writer.Close()
+	return buf.Bytes()
+}
+
+type bodyEchoRoundTripper struct{}
+
+func (rt *bodyEchoRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestDecodeBody_Encodings(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br"} {
+		contentEncoding := encoding
+		if encoding == "raw-deflate" {
+			contentEncoding = "deflate"
+		}
+
+		// Act
+		decoded, err := decodeBody(contentEncoding, compressMust(encoding, `{"id": 1}`))
+
+		// Assert
+		assert.Nil(t, err, encoding)
+		assert.Equal(t, `{"id": 1}`, string(decoded), encoding)
+	}
+}
+
+func TestDecodeBody_SeveralEncodings(t *testing.T) {
+	body := compressMust("br", string(compressMust("gzip", "abc")))
+
+	decoded, err := decodeBody("gzip, br", body)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(decoded))
+}
+
+func TestDecodeBody_UnsupportedOrInvalid_Error(t *testing.T) {
+	_, err := decodeBody("compress", []byte("abc"))
+	assert.NotNil(t, err)
+
+	_, err = decodeBody("gzip", []byte("abc"))
+	assert.NotNil(t, err)
+}
+
+func TestHttpRequestToIncomingRequest_GzipBody(t *testing.T) {
+	compressed := compressMust("gzip", "user=a")
+	req := httpNewRequestMust("POST", "/", bytes.NewReader(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Act
+	incoming, err := HttpRequestToIncomingRequest(req)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "user=a", incoming.Body)
+	assert.Equal(t, map[string][]string{"user": {"a"}}, incoming.Form)
+	assert.Equal(t, compressed, incoming.forwardBody())
+}
+
+func TestGzFilter_Apply_DecodedBodyMatchedAndRawBodyForwarded(t *testing.T) {
+	filter := NewGzFilter(&bodyEchoRoundTripper{}, NewGzStorage())
+	filter.Add(Expectation{
+		Key:     "k",
+		Request: &ExpectationRequest{Body: StringMatcher{Contains: `"id": 1`}},
+		Forward: &ExpectationForward{Scheme: "http", Host: "backend"},
+	})
+
+	compressed := compressMust("br", `{"id": 1}`)
+	req := httpNewRequestMust("POST", "/", bytes.NewReader(compressed))
+	req.Header.Set("Content-Encoding", "br")
+
+	// Act
+	resp := filter.Apply(req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, compressed, resp.Body)
+}
@@ -4,28 +4,204 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/rs/zerolog/log"
 )
 
-// Headers are HTTP headers
-type Headers map[string]string
+// Headers are HTTP headers. Header may have several values.
+// JSON accepts a single string as well as an array of strings per header
+type Headers map[string][]string
+
+// UnmarshalJSON accepts single string or array of strings as header value
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	headers := Headers{}
+	for name, value := range raw {
+		var values []string
+		if err := json.Unmarshal(value, &values); err != nil {
+			var single string
+			if err := json.Unmarshal(value, &single); err != nil {
+				return err
+			}
+			values = []string{single}
+		}
+		headers[name] = values
+	}
+	*h = headers
+	return nil
+}
+
+// MarshalJSON writes header with a single value as string
+func (h Headers) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{}, len(h))
+	for name, values := range h {
+		if len(values) == 1 {
+			raw[name] = values[0]
+		} else {
+			raw[name] = []string(values)
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// Values returns all values of the header using case-insensitive lookup
+func (h Headers) Values(name string) []string {
+	var values []string
+	for hName, hValues := range h {
+		if strings.EqualFold(hName, name) {
+			values = append(values, hValues...)
+		}
+	}
+	return values
+}
+
+// IncomingRequest is incoming http request translated for matching and templates
+type IncomingRequest struct {
+	Scheme  string
+	Host    string
+	Method  string
+	Path    string
+	URLPath string
+	Query   url.Values
+	Body    string
+	Headers Headers
+	Cookies map[string][]string
+
+	// Form and FormParts are parsed from form-urlencoded or multipart/form-data body
+	Form      map[string][]string
+	FormParts []FormPart
+
+	// GraphQL is operation parsed from GraphQL request, it's nil for other requests
+	GraphQL *GraphQLRequest
+
+	// ClientIP is address of client, ClientCert is TLS client certificate or nil if client didn't present one
+	ClientIP   string
+	ClientCert *ClientCertificate
+
+	// PathParams are variables captured by path template of matched expectation
+	PathParams map[string]string
+
+	// rawBody is original body, which is forwarded unchanged. Body is decoded according to Content-Encoding
+	rawBody []byte
+}
 
 // ExpectationRequest is filter for incoming requests
 type ExpectationRequest struct {
-	Method  string  `json:"method"`
-	Path    string  `json:"path"`
-	Body    string  `json:"body"`
-	Headers Headers `json:"headers,omitempty"`
+	Scheme  StringMatcher  `json:"scheme"`
+	Host    StringMatcher  `json:"host"`
+	Method  StringMatcher  `json:"method"`
+	Path    StringMatcher  `json:"path"`
+	Body    StringMatcher  `json:"body"`
+	Headers ValuesMatchers `json:"headers,omitempty"`
+	Query   ValuesMatchers `json:"query,omitempty"`
+	Cookies ValuesMatchers `json:"cookies,omitempty"`
+	Form    *FormMatcher   `json:"form,omitempty"`
+
+	JSONBody *JSONBodyMatcher  `json:"jsonBody,omitempty"`
+	JSONPath []JSONPathMatcher `json:"jsonPath,omitempty"`
+	XPath    *XPathMatcher     `json:"xpath,omitempty"`
+
+	JSONSchema *JSONSchemaMatcher `json:"jsonSchema,omitempty"`
+	GraphQL    *GraphQLMatcher    `json:"graphql,omitempty"`
+
+	// ClientCIDR is list of networks, e.g. 10.0.1.0/24, client address should belong to one of them
+	ClientCIDR []string           `json:"clientCidr,omitempty"`
+	ClientCert *ClientCertMatcher `json:"clientCert,omitempty"`
+
+	// JsPredicate is base64 encoded JS expression which gets the same objects as JsTemplate and returns true or false
+	JsPredicate string `json:"jsPredicate,omitempty"`
+
+	// AnyOf requires at least one nested filter to pass, AllOf requires all of them, Not requires nested filter to fail.
+	// Groups are checked in addition to other fields of the filter
+	AnyOf []ExpectationRequest `json:"anyOf,omitempty"`
+	AllOf []ExpectationRequest `json:"allOf,omitempty"`
+	Not   *ExpectationRequest  `json:"not,omitempty"`
+
+	// compiled path template, client networks and JS predicate are set by compile
+	compiled       bool
+	pathTemplate   *regexp.Regexp
+	clientNetworks []*net.IPNet
+	jsPredicate    *otto.Script
+	jsPredicateErr error
+}
+
+// compile precompiles regexes, parses expressions and loads schemas of all filters once,
+// so they aren't compiled for every incoming request. Returns the first error, invalid filters never pass
+func (exp *ExpectationRequest) compile() error {
+	var errs []error
+	for _, matcher := range []*StringMatcher{&exp.Scheme, &exp.Host, &exp.Method, &exp.Path, &exp.Body} {
+		errs = append(errs, matcher.compile())
+	}
+	errs = append(errs, exp.Headers.compile(), exp.Query.compile(), exp.Cookies.compile())
+
+	var err error
+	exp.pathTemplate, err = compilePathTemplate(exp.Path.Value)
+	errs = append(errs, err)
+
+	if exp.Form != nil {
+		errs = append(errs, exp.Form.compile())
+	}
+	if exp.JSONBody != nil {
+		errs = append(errs, exp.JSONBody.compile())
+	}
+	for i := range exp.JSONPath {
+		errs = append(errs, exp.JSONPath[i].compile())
+	}
+	if exp.XPath != nil {
+		errs = append(errs, exp.XPath.compile())
+	}
+	if exp.JSONSchema != nil {
+		errs = append(errs, exp.JSONSchema.compile())
+	}
+	if exp.GraphQL != nil {
+		errs = append(errs, exp.GraphQL.compile())
+	}
+	exp.clientNetworks, err = parseCIDRs(exp.ClientCIDR)
+	errs = append(errs, err)
+	if exp.ClientCert != nil {
+		errs = append(errs, exp.ClientCert.compile())
+	}
+	exp.jsPredicate, exp.jsPredicateErr = compileJsPredicate(exp.JsPredicate)
+	errs = append(errs, exp.jsPredicateErr)
+	for i := range exp.AnyOf {
+		errs = append(errs, exp.AnyOf[i].compile())
+	}
+	for i := range exp.AllOf {
+		errs = append(errs, exp.AllOf[i].compile())
+	}
+	if exp.Not != nil {
+		errs = append(errs, exp.Not.compile())
+	}
+	exp.compiled = true
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // ExpectationForward is forward action if request passes filter
 type ExpectationForward struct {
-	Scheme  string  `json:"scheme"`
-	Host    string  `json:"host"`
+	Scheme string `json:"scheme"`
+	Host   string `json:"host"`
+	// Port replaces port of host, it's used to forward to incoming host on particular port
+	Port    string  `json:"port,omitempty"`
 	Headers Headers `json:"headers,omitempty"`
 }
 
@@ -35,6 +211,34 @@ type ExpectationResponse struct {
 	Body       string  `json:"body"`
 	Headers    Headers `json:"headers,omitempty"`
 	JsTemplate string  `json:"jstemplate,omitempty"`
+	// Template is Go text/template of response body, it's used if JsTemplate isn't set
+	Template string `json:"template,omitempty"`
+	// BodyBase64 is base64 encoded binary body, it's used if templates aren't set
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+	// BodyFile is path of body file in mock data directory, it's used if templates and BodyBase64 aren't set
+	BodyFile string `json:"bodyFile,omitempty"`
+
+	// parsed Go template and decoded binary body are set by compile
+	compiled      bool
+	template      *template.Template
+	templateErr   error
+	bodyBase64    []byte
+	bodyBase64Err error
+}
+
+// compile parses Go template and decodes binary body once, so it isn't done for every response
+func (exp *ExpectationResponse) compile() error {
+	exp.compiled = true
+	if len(exp.Template) > 0 {
+		exp.template, exp.templateErr = compileGoTemplate(exp.Template)
+	}
+	if len(exp.BodyBase64) > 0 {
+		exp.bodyBase64, exp.bodyBase64Err = decodeBodyBase64(exp.BodyBase64)
+	}
+	if exp.templateErr != nil {
+		return exp.templateErr
+	}
+	return exp.bodyBase64Err
 }
 
 // Expectation is single set of rules: expected request and prepared action
@@ -43,8 +247,10 @@ type Expectation struct {
 	Request  *ExpectationRequest  `json:"request,omitempty"`
 	Forward  *ExpectationForward  `json:"forward,omitempty"`
 	Response *ExpectationResponse `json:"response,omitempty"`
-	Delay    time.Duration        `json:"delay,omitempty"`
-	Priority int                  `json:"priority,omitempty"`
+	// Sequence is used instead of Response, every matching request gets the next response of it
+	Sequence *ResponseSequence `json:"sequence,omitempty"`
+	Delay    time.Duration     `json:"delay,omitempty"`
+	Priority int               `json:"priority,omitempty"`
 }
 
 // ExpectationRemove removes action from list by key
@@ -52,6 +258,11 @@ type ExpectationRemove struct {
 	Key string `json:"key"`
 }
 
+// ExpectationReset moves response sequence of expectation to the first response. Empty key resets all sequences
+type ExpectationReset struct {
+	Key string `json:"key"`
+}
+
 // Expectations is a map for expectations
 type Expectations map[string]Expectation
 
@@ -68,13 +279,17 @@ type Storer interface {
 	AddFromJSON(file string) error
 	AddFromString(str string) error
 	Remove(key string)
+	Reset(key string) bool
 	GetOrdered() OrderedExpectations
+	GetCandidates(req *IncomingRequest) []Expectation
 }
 
 // gzStorage is a structure with mutex to control access to expectations
 type gzStorage struct {
 	expectations Expectations
-	mu           sync.RWMutex
+	// index of ordered expectations is cached, it's reset when expectations are changed
+	index *expectationIndex
+	mu    sync.RWMutex
 }
 
 // NewGzStorage is gzStorage constructor
@@ -84,8 +299,25 @@ func NewGzStorage() Storer {
 
 // Add a new expectation to list. If expectation with same key exists, updates it
 func (storage *gzStorage) Add(exp Expectation) {
+	if exp.Request != nil {
+		if err := exp.Request.compile(); err != nil {
+			log.Warn().Str("messagetype", "storageAdd").Err(err).Msgf("Expectation %s has invalid filter", exp.Key)
+		}
+	}
+	if exp.Response != nil {
+		if err := exp.Response.compile(); err != nil {
+			log.Warn().Str("messagetype", "storageAdd").Err(err).Msgf("Expectation %s has invalid response", exp.Key)
+		}
+	}
+	if exp.Sequence != nil {
+		if err := exp.Sequence.compile(); err != nil {
+			log.Warn().Str("messagetype", "storageAdd").Err(err).Msgf("Expectation %s has invalid response sequence", exp.Key)
+		}
+	}
+
 	storage.mu.Lock()
 	storage.expectations[exp.Key] = exp
+	storage.index = nil
 	storage.mu.Unlock()
 }
 
@@ -132,10 +364,27 @@ func (storage *gzStorage) Remove(key string) {
 	if ok {
 		storage.mu.Lock()
 		delete(storage.expectations, key)
+		storage.index = nil
 		storage.mu.Unlock()
 	}
 }
 
+// Reset moves response sequence of expectation with particular key to the first response.
+// Empty key resets all sequences. Returns false if there is no such expectation with sequence
+func (storage *gzStorage) Reset(key string) bool {
+	storage.mu.RLock()
+	defer storage.mu.RUnlock()
+
+	found := false
+	for expKey, exp := range storage.expectations {
+		if exp.Sequence != nil && (len(key) == 0 || expKey == key) {
+			exp.Sequence.reset()
+			found = true
+		}
+	}
+	return found
+}
+
 // OrderedExpectations is for sorting expectations by priority. the lowest priority is 0
 type OrderedExpectations map[int]Expectation
 
@@ -144,25 +393,55 @@ func (exps OrderedExpectations) Swap(i, j int)      { exps[i], exps[j] = exps[j]
 func (exps OrderedExpectations) Less(i, j int) bool { return exps[i].Priority > exps[j].Priority }
 
 // GetOrdered returns map with int keys sorted by priority DESC.
-// 0-indexed element has the highest priority
+// 0-indexed element has the highest priority.
+// Sorted list is cached until expectations are changed, so it must not be modified by caller
 func (storage *gzStorage) GetOrdered() OrderedExpectations {
+	return storage.getIndex().ordered
+}
+
+// GetCandidates returns expectations which may match the request by method and path,
+// in the same order as GetOrdered returns them
+func (storage *gzStorage) GetCandidates(req *IncomingRequest) []Expectation {
+	return storage.getIndex().candidates(req)
+}
+
+// getIndex returns cached index of ordered expectations, it's built if expectations were changed
+func (storage *gzStorage) getIndex() *expectationIndex {
+	storage.mu.RLock()
+	index := storage.index
+	storage.mu.RUnlock()
+	if index != nil {
+		return index
+	}
+
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+	if storage.index != nil {
+		return storage.index
+	}
+
 	listForSorting := OrderedExpectations{}
 	i := 0
-	storage.mu.RLock()
 	for _, exp := range storage.expectations {
 		listForSorting[i] = exp
 		i++
 	}
-	storage.mu.RUnlock()
 	sort.Sort(listForSorting)
-	return listForSorting
+	storage.index = newExpectationIndex(listForSorting)
+	return storage.index
 }
 
-// HttpRequestToExpectationRequest Translates http request to expectation request
-func HttpRequestToExpectationRequest(r *http.Request) (*ExpectationRequest, error) {
-	var expRequest = ExpectationRequest{}
+// HttpRequestToIncomingRequest Translates http request to incoming request
+func HttpRequestToIncomingRequest(r *http.Request) (*IncomingRequest, error) {
+	var expRequest = IncomingRequest{}
+	expRequest.Scheme = requestScheme(r)
+	expRequest.Host = r.Host
 	expRequest.Method = r.Method
 	expRequest.Path = r.URL.RequestURI()
+	expRequest.URLPath = r.URL.Path
+	expRequest.Query = r.URL.Query()
+	expRequest.ClientIP = clientIP(r)
+	expRequest.ClientCert = clientCertificate(r)
 
 	if len(r.URL.Fragment) > 0 {
 		expRequest.Path += "#" + r.URL.Fragment
@@ -173,19 +452,55 @@ func HttpRequestToExpectationRequest(r *http.Request) (*ExpectationRequest, erro
 		if err != nil {
 			return nil, err
 		}
+		expRequest.rawBody = bodyContent
 		expRequest.Body = string(bodyContent)
+
+		if encoding := r.Header.Get("Content-Encoding"); len(encoding) > 0 {
+			decoded, err := decodeBody(encoding, bodyContent)
+			if err != nil {
+				log.Debug().Str("messagetype", "decodeBody").Err(err).Msg("Request body is matched as is")
+			} else {
+				expRequest.Body = string(decoded)
+			}
+		}
 	}
 
 	if len(r.Header) > 0 {
 		expRequest.Headers = Headers{}
 		for name, headerLine := range r.Header {
-			expRequest.Headers[name] = strings.Join(headerLine, ",")
+			expRequest.Headers[name] = append([]string(nil), headerLine...)
+		}
+	}
+
+	form, parts, err := parseForm(r.Header.Get("Content-Type"), expRequest.Body)
+	if err == nil {
+		expRequest.Form = form
+		expRequest.FormParts = parts
+	}
+
+	expRequest.GraphQL = parseGraphQL(r.Method, expRequest.Query, r.Header.Get("Content-Type"), expRequest.Body)
+
+	if cookies := r.Cookies(); len(cookies) > 0 {
+		expRequest.Cookies = map[string][]string{}
+		for _, cookie := range cookies {
+			expRequest.Cookies[cookie.Name] = append(expRequest.Cookies[cookie.Name], cookie.Value)
 		}
 	}
 
 	return &expRequest, nil
 }
 
+// requestScheme returns scheme used by client. X-Forwarded-Proto is used if request comes from trusted proxy
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 && trustedProxy(remoteIP(r)) {
+		return strings.ToLower(proto)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
 // HttpRequestToExpectationRemove Translates http request to expectationRemove
 func HttpRequestToExpectationRemove(r *http.Request) (*ExpectationRemove, error) {
 	expRemove := ExpectationRemove{}
@@ -198,6 +513,18 @@ func HttpRequestToExpectationRemove(r *http.Request) (*ExpectationRemove, error)
 	return &expRemove, nil
 }
 
+// HttpRequestToExpectationReset Translates http request to ExpectationReset
+func HttpRequestToExpectationReset(r *http.Request) (*ExpectationReset, error) {
+	expReset := ExpectationReset{}
+
+	err := json.NewDecoder(r.Body).Decode(&expReset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &expReset, nil
+}
+
 // HttpRequestToExpectation Translates http request to expectation
 func HttpRequestToExpectation(r *http.Request) (*Expectation, error) {
 	exp := Expectation{}
@@ -1,6 +1,7 @@
 package expectations
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -88,6 +89,54 @@ func TestGzStorage_Order(t *testing.T) {
 	assert.Equal(t, "p5", res[2].Key)
 }
 
+func TestGzStorage_OrderCachedUntilChanged(t *testing.T) {
+	storage := NewGzStorage()
+	storage.Add(Expectation{Key: "p5", Priority: 5})
+
+	// Act
+	cached := storage.GetOrdered()
+	storage.Add(Expectation{Key: "p10", Priority: 10})
+	afterAdd := storage.GetOrdered()
+	storage.Remove("p10")
+	afterRemove := storage.GetOrdered()
+
+	// Assert
+	assert.Equal(t, 1, len(cached))
+	assert.Equal(t, 2, len(afterAdd))
+	assert.Equal(t, "p10", afterAdd[0].Key)
+	assert.Equal(t, 1, len(afterRemove))
+	assert.Equal(t, "p5", afterRemove[0].Key)
+}
+
+func TestGzStorage_AddCompilesRequest(t *testing.T) {
+	storage := NewGzStorage()
+	req := &ExpectationRequest{
+		Path:     StringMatcher{Value: "/users/{id}"},
+		Headers:  ValuesMatchers{"h": {{Regex: "^v"}}},
+		JSONPath: []JSONPathMatcher{{Path: "$.id", Regex: "[0-9]+"}},
+	}
+
+	// Act
+	storage.Add(Expectation{Key: "k", Request: req})
+
+	// Assert
+	assert.True(t, req.compiled)
+	assert.NotNil(t, req.pathTemplate)
+	assert.NotNil(t, req.Headers["h"][0].regexRe)
+	assert.NotNil(t, req.JSONPath[0].regex)
+}
+
+func TestExpectationRequest_CompileInvalidRegex_NeverMatches(t *testing.T) {
+	exp := &ExpectationRequest{Path: StringMatcher{Regex: "("}}
+
+	// Act
+	err := exp.compile()
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.False(t, expectationsMatch(&IncomingRequest{Path: "("}, exp))
+}
+
 func TestGzStorage_AddFromJson_Ok(t *testing.T) {
 	str := "[{\"key\": \"k\"}]"
 	file := "test.json"
@@ -137,23 +186,86 @@ func TestGzStorage_AddFromString_DefaultValues(t *testing.T) {
 	assert.Equal(t, "http", exps[0].Forward.Scheme)
 }
 
-func TestHttpRequestToExpectationRequest_SimpleRequest_AllFieldsTranslated(t *testing.T) {
+func TestHttpRequestToIncomingRequest_SimpleRequest_AllFieldsTranslated(t *testing.T) {
 	request, err := http.NewRequest("POST", "https://www.host.com/a/b?foo=bar#fr", strings.NewReader("body text"))
 	if err != nil {
 		t.Fatal(err)
 	}
 	request.Header.Add("h1", "hv1")
 	request.Header.Add("h1", "hv2")
+	request.Header.Add("Cookie", "c1=cv1; c2=cv2")
 
 	// Act
-	exp, err := HttpRequestToExpectationRequest(request)
+	exp, err := HttpRequestToIncomingRequest(request)
 
 	// Assert
 	assert.Nil(t, err)
 	assert.NotNil(t, exp)
 	assert.Equal(t, "POST", exp.Method)
 	assert.Equal(t, "/a/b?foo=bar#fr", exp.Path)
+	assert.Equal(t, "/a/b", exp.URLPath)
+	assert.Equal(t, "bar", exp.Query.Get("foo"))
 	assert.Equal(t, "body text", string(exp.Body))
-	assert.Equal(t, 1, len(exp.Headers))
-	assert.Equal(t, "hv1,hv2", exp.Headers["H1"])
+	assert.Equal(t, 2, len(exp.Headers))
+	assert.Equal(t, []string{"hv1", "hv2"}, exp.Headers["H1"])
+	assert.Equal(t, map[string][]string{"c1": {"cv1"}, "c2": {"cv2"}}, exp.Cookies)
+}
+
+func TestHeaders_UnmarshalSingleAndMultipleValues(t *testing.T) {
+	var h Headers
+
+	// Act
+	err := json.Unmarshal([]byte(`{"Content-Type": "text/plain", "Set-Cookie": ["a=1", "b=2"]}`), &h)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, Headers{"Content-Type": {"text/plain"}, "Set-Cookie": {"a=1", "b=2"}}, h)
+}
+
+func TestHeaders_MarshalSingleValueAsString(t *testing.T) {
+	// Act
+	res, err := json.Marshal(Headers{"Content-Type": {"text/plain"}, "Set-Cookie": {"a=1", "b=2"}})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, `{"Content-Type":"text/plain","Set-Cookie":["a=1","b=2"]}`, string(res))
+}
+
+func TestHeaders_ValuesCaseInsensitive(t *testing.T) {
+	h := Headers{"Link": {"<a>"}, "X-Id": {"1"}}
+
+	assert.Equal(t, []string{"<a>"}, h.Values("link"))
+	assert.Nil(t, h.Values("accept"))
+}
+
+func TestHttpRequestToIncomingRequest_HostAndScheme(t *testing.T) {
+	request := httpNewRequestMust("GET", "http://api.supplier-a.com:8080/a", nil)
+
+	// Act
+	exp, err := HttpRequestToIncomingRequest(request)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "http", exp.Scheme)
+	assert.Equal(t, "api.supplier-a.com:8080", exp.Host)
+}
+
+func TestHttpRequestToIncomingRequest_ForwardedProto(t *testing.T) {
+	defer withTrustedProxies(t, "10.0.0.1")()
+	request := httpNewRequestMust("GET", "http://api.supplier-a.com/a", nil)
+	request.Header.Add("X-Forwarded-Proto", "HTTPS")
+	request.RemoteAddr = "10.0.0.1:1234"
+	untrusted := httpNewRequestMust("GET", "http://api.supplier-a.com/a", nil)
+	untrusted.Header.Add("X-Forwarded-Proto", "HTTPS")
+	untrusted.RemoteAddr = "10.0.0.2:1234"
+
+	// Act
+	exp, err := HttpRequestToIncomingRequest(request)
+	expUntrusted, errUntrusted := HttpRequestToIncomingRequest(untrusted)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "https", exp.Scheme)
+	assert.Nil(t, errUntrusted)
+	assert.Equal(t, "http", expUntrusted.Scheme)
 }
@@ -5,9 +5,10 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
-	"regexp"
+	"os"
 	"strings"
 	"time"
 
@@ -32,6 +33,10 @@ type HttpResponse struct {
 	HTTPCode int     `json:"httpcode"`
 	Body     []byte  `json:"body"`
 	Headers  Headers `json:"headers,omitempty"`
+
+	// bodyFile is opened file which is streamed instead of Body, WriteBody closes it
+	bodyFile     *os.File
+	bodyFileSize int64
 }
 
 func NewGzFilter(rt http.RoundTripper, storage Storer) *GzFilter {
@@ -57,39 +62,59 @@ func (f *GzFilter) Remove(key string) {
 	f.storage.Remove(key)
 }
 
+func (f *GzFilter) Reset(key string) bool {
+	return f.storage.Reset(key)
+}
+
 func (f *GzFilter) GetOrdered() OrderedExpectations {
 	return f.storage.GetOrdered()
 }
 
+func (f *GzFilter) GetCandidates(req *IncomingRequest) []Expectation {
+	return f.storage.GetCandidates(req)
+}
+
 func (f *GzFilter) Apply(r *http.Request) *HttpResponse {
 	fLog := log.With().Str("messagetype", "generateResponseToResponseWriter").Logger()
-	req, err := HttpRequestToExpectationRequest(r)
+	req, err := HttpRequestToIncomingRequest(r)
 	if err != nil {
 		return reportError()
 	}
 
-	orderedStoredExpectations := f.storage.GetOrdered()
-	for i := 0; i < len(orderedStoredExpectations); i++ {
-		exp := orderedStoredExpectations[i]
-
-		if !expectationsMatch(req, exp.Request) {
+	for _, exp := range f.storage.GetCandidates(req) {
+		if mismatches := expectationMismatches(req, exp.Request); len(mismatches) > 0 {
+			if resp := jsonSchemaErrorResponse(exp.Request, mismatches); resp != nil {
+				fLog.Info().Str("key", exp.Key).Msg("Request body doesn't match JSON Schema")
+				return resp
+			}
 			continue
 		}
 
+		if exp.Sequence != nil {
+			// exp is a copy, so the next response of sequence is applied as its response
+			if exp.Response = exp.Sequence.next(); exp.Response == nil {
+				fLog.Debug().Str("key", exp.Key).Msg("Response sequence is exhausted, next expectation is checked")
+				continue
+			}
+		}
+
 		return f.applyExpectation(exp, req)
 	}
 
-	fLog.Error().Msg("No expectations in gozzmock for request!")
+	closest := f.closestMatches(req, closestMatchesCount)
+	fLog.Error().Strs("closest", closestMatchesToLog(closest)).Msg("No expectations in gozzmock for request!")
 
 	return &HttpResponse{
 		HTTPCode: http.StatusNotImplemented,
-		Body:     []byte("No expectations in gozzmock for request!"),
+		Body:     []byte("No expectations in gozzmock for request!" + closestMatchesToText(closest)),
 	}
 }
 
-func (f *GzFilter) applyExpectation(exp Expectation, req *ExpectationRequest) *HttpResponse {
+func (f *GzFilter) applyExpectation(exp Expectation, req *IncomingRequest) *HttpResponse {
 	fLog := log.With().Str("messagetype", "applyExpectation").Str("key", exp.Key).Logger()
 
+	req.PathParams = pathParams(req, exp.Request)
+
 	if exp.Delay > 0 {
 		fLog.Info().Msg(fmt.Sprintf("Delay %v sec", exp.Delay))
 		time.Sleep(time.Second * exp.Delay)
@@ -115,7 +140,7 @@ func reportError() *HttpResponse {
 	}
 }
 
-func responseFromExpectation(exp *ExpectationResponse, req *ExpectationRequest) *HttpResponse {
+func responseFromExpectation(exp *ExpectationResponse, req *IncomingRequest) *HttpResponse {
 	// NOTE
 	// Changing the header map after a call to WriteHeader (or
 	// Write) has no effect unless the modified headers are
@@ -124,47 +149,98 @@ func responseFromExpectation(exp *ExpectationResponse, req *ExpectationRequest)
 
 	resp := HttpResponse{HTTPCode: exp.HTTPCode}
 	if exp.Headers != nil {
-		for name, value := range exp.Headers {
-			resp.Headers[name] = value
+		headers, err := renderHeaders(exp.Headers, req)
+		if err != nil {
+			resp.HTTPCode = http.StatusInternalServerError
+			resp.Body = []byte(err.Error())
+			fLog.Error().Err(err).Msg("")
+			return &resp
 		}
+		resp.Headers = headers
 	}
 
 	resposneBody := exp.Body
-	if len(exp.JsTemplate) > 0 {
+	if len(exp.JsTemplate) > 0 || len(exp.Template) > 0 {
 		var err error
-		resposneBody, err = runJsTemplate(exp.JsTemplate, req)
+		if len(exp.JsTemplate) > 0 {
+			resposneBody, err = runJsTemplateResponse(exp.JsTemplate, req, &resp)
+		} else {
+			resposneBody, err = exp.runGoTemplate(req)
+		}
 		if err != nil {
 			resp.HTTPCode = http.StatusInternalServerError
 			resp.Body = []byte(err.Error())
 			fLog.Error().Err(err).Msg("")
 			return &resp
 		}
+	} else if len(exp.BodyBase64) > 0 || len(exp.BodyFile) > 0 {
+		if err := exp.setBinaryBody(&resp); err != nil {
+			resp.HTTPCode = http.StatusInternalServerError
+			resp.Body = []byte(err.Error())
+			fLog.Error().Err(err).Msg("")
+		}
+		return &resp
 	}
 	resp.Body = []byte(resposneBody)
 
 	return &resp
 }
 
-// runJsTemplate creates response body as string based on template and incoming request
-func runJsTemplate(encodedTmpl string, req *ExpectationRequest) (string, error) {
-	if len(encodedTmpl) == 0 {
-		return "", nil
-	}
-
+// evalJsTemplate runs template and returns its result
+func evalJsTemplate(encodedTmpl string, req *IncomingRequest) (otto.Value, error) {
 	decodedTmpl, err := base64.StdEncoding.DecodeString(encodedTmpl)
 	if err != nil {
-		return "", fmt.Errorf("Error decoding from base64 template %s \n %s", encodedTmpl, err.Error())
+		return otto.UndefinedValue(), fmt.Errorf("Error decoding from base64 template %s \n %s", encodedTmpl, err.Error())
 	}
 	stringTmpl := string(decodedTmpl)
 
-	vm := otto.New()
-	vm.Set("request", req)
+	vm := newJsVM(req, req.PathParams)
 	value, err := vm.Run(stringTmpl)
 	if err != nil {
-		return "", fmt.Errorf("Error running template %s \n %s", stringTmpl, err.Error())
+		return otto.UndefinedValue(), fmt.Errorf("Error running template %s \n %s", stringTmpl, err.Error())
+	}
+	return value, nil
+}
+
+// jsRequest is incoming request for JS scripts. Headers are joined with comma, like in scripts written
+// before multiple values were kept, HeaderValues has all values of every header
+type jsRequest struct {
+	*IncomingRequest
+	Headers      map[string]string
+	HeaderValues Headers
+}
+
+// newJSRequest creates request object for JS scripts
+func newJSRequest(req *IncomingRequest) *jsRequest {
+	headers := make(map[string]string, len(req.Headers))
+	for name, values := range req.Headers {
+		headers[name] = strings.Join(values, ",")
+	}
+	return &jsRequest{IncomingRequest: req, Headers: headers, HeaderValues: req.Headers}
+}
+
+// newJsVM creates JS runtime with incoming request, path parameters, form and GraphQL operation as global objects
+func newJsVM(req *IncomingRequest, pathParams map[string]string) *otto.Otto {
+	if pathParams == nil {
+		pathParams = map[string]string{}
+	}
+
+	form := req.Form
+	if form == nil {
+		form = map[string][]string{}
 	}
 
-	return value.String(), nil
+	graphql := req.GraphQL
+	if graphql == nil {
+		graphql = &GraphQLRequest{Variables: map[string]interface{}{}}
+	}
+
+	vm := otto.New()
+	vm.Set("request", newJSRequest(req))
+	vm.Set("pathParams", pathParams)
+	vm.Set("form", form)
+	vm.Set("graphql", graphql)
+	return vm
 }
 
 func (f *GzFilter) doHTTPRequest(httpReq *http.Request) *HttpResponse {
@@ -208,107 +284,262 @@ func (f *GzFilter) doHTTPRequest(httpReq *http.Request) *HttpResponse {
 	return resp
 }
 
-// stringsMatch validates whether the input string has filter string as substring or as a regex
-func stringsMatch(req string, exp string) bool {
-	if len(exp) == 0 {
-		return true
+// hostname returns host without port
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
 	}
-
-	r, err := regexp.Compile("(?s)" + exp)
-	if err != nil {
-		return strings.Contains(req, exp)
-	}
-	return r.MatchString(req)
+	return host
 }
 
-// findInMapCaseInsensitive looks up the specified key in the map using case-insensitive lookup
-func findInMapCaseInsensitive(m map[string]string, k string) (string, bool) {
-	for name, value := range m {
-		if strings.EqualFold(name, k) {
-			return value, true
-		}
+// methodsMatch validates whether the request method passes filter. Plain string should be equal to method
+func methodsMatch(req string, exp StringMatcher) bool {
+	if !exp.hasOperators() {
+		return len(exp.Value) == 0 || req == exp.Value
 	}
-
-	return "", false
+	return exp.Match(req)
 }
 
-// headersMatch validates whether the input string has filter string as substring or as a regex
-func headersMatch(req Headers, exp Headers) bool {
-	if len(exp) == 0 {
-		return true
+// namesMatch validates whether the request scheme or host passes filter.
+// Plain string should be equal to the name ignoring case, object form uses operators
+func namesMatch(req string, exp StringMatcher) bool {
+	if !exp.hasOperators() {
+		return len(exp.Value) == 0 || strings.EqualFold(req, exp.Value)
 	}
+	return exp.Match(req)
+}
 
-	for expName, expValue := range exp {
-		reqValue, ok := findInMapCaseInsensitive(req, expName)
-		if ok && stringsMatch(reqValue, expValue) {
+// headersMatch validates whether the request headers pass all header filters.
+// Header names are case-insensitive. Returns name of failed header
+func headersMatch(req Headers, exp ValuesMatchers) (string, bool) {
+	for expName, expValues := range exp {
+		reqValues := req.Values(expName)
+		if expValues.Match(reqValues) {
+			continue
+		}
+		// repeated headers used to be joined with comma, legacy plain filter may expect the joined value.
+		// Operators aren't checked against joined value, so negated filter can't be bypassed by repeating header
+		if len(reqValues) > 1 && expValues.plain() && expValues.Match([]string{strings.Join(reqValues, ",")}) {
 			continue
 		}
-		return false
+		return expName, false
 	}
-	return true
+	return "", true
 }
 
 // expectationsMatch validates whether the incoming request passes particular filter
-func expectationsMatch(req *ExpectationRequest, exp *ExpectationRequest) bool {
+func expectationsMatch(req *IncomingRequest, exp *ExpectationRequest) bool {
+	return len(expectationMismatches(req, exp)) == 0
+}
+
+// expectationMismatches returns fields of the incoming request which don't pass particular filter,
+// checking stops at the first mismatch. Empty result means the request passes the filter
+func expectationMismatches(req *IncomingRequest, exp *ExpectationRequest) []mismatch {
 	fLog := log.With().Str("messagetype", "controllerRequestPassesFilter").Logger()
 
 	if exp == nil {
 		fLog.Debug().Msg("Match. Expectation is nil")
-		return true
+		return nil
+	}
+
+	mismatches := requestMismatches(req, exp, firstMismatch)
+	if len(mismatches) > 0 {
+		fLog.Debug().Msgf("No match. %s", mismatches[0])
+	}
+	return mismatches
+}
+
+// mismatch is a field of incoming request which doesn't pass the filter.
+// Reason is formatted only when it's needed
+type mismatch struct {
+	field  string
+	format string
+	args   []interface{}
+	// errors are validation errors of JSON Schema, they're used for 400 response
+	errors []string
+}
+
+// String returns field and reason of mismatch
+func (m mismatch) String() string {
+	return m.field + ": " + fmt.Sprintf(m.format, m.args...)
+}
+
+// mismatchMode defines how requestMismatches checks the filter
+type mismatchMode int
+
+const (
+	// firstMismatch stops checking at the first mismatch, it's used for matching.
+	// JSON Schema which responds with errors doesn't stop checking, the response requires the rest of filter to pass
+	firstMismatch mismatchMode = iota
+	// allMismatches checks all fields for diagnostics of unmatched request.
+	// JS predicates aren't run, they may be slow and were already run for matching
+	allMismatches
+)
+
+// requestMismatches returns fields of the incoming request which don't pass the filter
+func requestMismatches(req *IncomingRequest, exp *ExpectationRequest, mode mismatchMode) []mismatch {
+	if exp == nil {
+		return nil
+	}
+
+	var mismatches []mismatch
+	// fail adds mismatch and returns true if checking should stop
+	fail := func(field string, format string, args ...interface{}) bool {
+		mismatches = append(mismatches, mismatch{field: field, format: format, args: args})
+		return mode == firstMismatch
+	}
+
+	if !namesMatch(req.Scheme, exp.Scheme) &&
+		fail("scheme", "request scheme %s doesn't match %s", req.Scheme, exp.Scheme) {
+		return mismatches
+	}
+
+	if !namesMatch(hostname(req.Host), exp.Host) &&
+		fail("host", "request host %s doesn't match %s", req.Host, exp.Host) {
+		return mismatches
+	}
+
+	if !clientCIDRMatch(req.ClientIP, exp) &&
+		fail("clientCidr", "client address %s doesn't belong to %v", req.ClientIP, exp.ClientCIDR) {
+		return mismatches
+	}
+
+	if name, ok := clientCertMatch(req.ClientCert, exp.ClientCert); !ok &&
+		fail("clientCert "+name, "client certificate %s doesn't pass filter of %s", clientCertSubject(req.ClientCert), name) {
+		return mismatches
+	}
+
+	if !methodsMatch(req.Method, exp.Method) &&
+		fail("method", "request method %s != %s", req.Method, exp.Method) {
+		return mismatches
+	}
+
+	if reqPath, ok := pathsMatch(req, exp); !ok &&
+		fail("path", "request path %s doesn't match %s", reqPath, exp.Path) {
+		return mismatches
+	}
+
+	if name, ok := exp.Query.Match(req.Query); !ok &&
+		fail("query "+name, "request query %v doesn't match %s", req.Query[name], exp.Query[name]) {
+		return mismatches
+	}
+
+	if !exp.Body.Match(req.Body) &&
+		fail("body", "request body %s doesn't match %s", shorten(req.Body), exp.Body) {
+		return mismatches
+	}
+
+	if !jsonBodyMatch(req.Body, exp.JSONBody) &&
+		fail("jsonBody", "request body %s doesn't match json %s", shorten(req.Body), exp.JSONBody.JSON) {
+		return mismatches
+	}
+
+	if path, ok := jsonPathsMatch(req.Body, exp.JSONPath); !ok &&
+		fail("jsonPath "+path, "request body %s doesn't match JSONPath %s", shorten(req.Body), path) {
+		return mismatches
+	}
+
+	if path, ok := xpathsMatch(req.Body, exp.XPath); !ok &&
+		fail("xpath "+path, "request body %s doesn't match XPath %s", shorten(req.Body), path) {
+		return mismatches
+	}
+
+	if errs, ok := jsonSchemaMatch(req.Body, exp.JSONSchema); !ok {
+		mismatches = append(mismatches, mismatch{field: "jsonSchema",
+			format: "request body %s doesn't match JSON Schema: %v", args: []interface{}{shorten(req.Body), errs}, errors: errs})
+		if mode == firstMismatch && !exp.JSONSchema.RespondWithErrors {
+			return mismatches
+		}
+	}
+
+	if name, ok := graphQLMatch(req.GraphQL, exp.GraphQL); !ok &&
+		fail("graphql "+name, "request GraphQL operation %s doesn't pass filter of %s", graphQLOperationName(req.GraphQL), name) {
+		return mismatches
+	}
+
+	if name, ok := formMatch(req, exp.Form); !ok &&
+		fail("form "+name, "request form %v doesn't pass filter of %s", req.Form, name) {
+		return mismatches
+	}
+
+	if name, ok := headersMatch(req.Headers, exp.Headers); !ok &&
+		fail("header "+name, "request header %v doesn't match %s", req.Headers.Values(name), exp.Headers[name]) {
+		return mismatches
 	}
 
-	if len(exp.Method) > 0 && exp.Method != req.Method {
-		fLog.Debug().Msgf("No match. Request method %s != %s", req.Method, exp.Method)
-		return false
+	if name, ok := exp.Cookies.Match(req.Cookies); !ok &&
+		fail("cookie "+name, "request cookie %v doesn't match %s", req.Cookies[name], exp.Cookies[name]) {
+		return mismatches
 	}
 
-	if !stringsMatch(req.Path, exp.Path) {
-		fLog.Debug().Msgf("No match. Request path %s doesn't match %s", req.Path, exp.Path)
-		return false
+	if group, reason, ok := groupsMatch(req, exp, mode); !ok &&
+		fail(group, "%s", reason) {
+		return mismatches
 	}
 
-	if !stringsMatch(string(req.Body), string(exp.Body)) {
-		fLog.Debug().Msgf("No match. Request body %s doesn't match %s", req.Body, exp.Body)
-		return false
+	if mode == allMismatches {
+		return mismatches
 	}
 
-	if !headersMatch(req.Headers, exp.Headers) {
-		fLog.Debug().Msgf("No match. Request headers %v doesn't match %v", req.Headers, exp.Headers)
-		return false
+	if reason, ok := jsPredicateMatch(req, exp); !ok &&
+		fail("jsPredicate", "%s", reason) {
+		return mismatches
 	}
 
-	return true
+	return mismatches
+}
+
+// shorten cuts long request body for logs and diagnostics
+func shorten(body string) string {
+	const maxLength = 200
+	if len(body) <= maxLength {
+		return body
+	}
+	return body[:maxLength] + "..."
 }
 
 // responseFromHTTPForward creates an http request based on incoming request and forward rules
-func (f *GzFilter) responseFromHTTPForward(req *ExpectationRequest, fwd *ExpectationForward) *HttpResponse {
+func (f *GzFilter) responseFromHTTPForward(req *IncomingRequest, fwd *ExpectationForward) *HttpResponse {
 	fLog := log.With().Str("messagetype", "responseFromHTTPForward").Logger()
 
-	fwdURL, err := url.Parse(fmt.Sprintf("%s://%s%s", fwd.Scheme, fwd.Host, req.Path))
+	// forward without host sends request to the host used by client.
+	// Client uses port of gozzmock, so it's replaced by port of forward or by default port of scheme
+	host := fwd.Host
+	if len(host) == 0 {
+		host = hostname(req.Host)
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	if len(fwd.Port) > 0 {
+		host = net.JoinHostPort(strings.Trim(hostname(host), "[]"), fwd.Port)
+	}
+
+	fwdURL, err := url.Parse(fmt.Sprintf("%s://%s%s", fwd.Scheme, host, req.Path))
 	if err != nil {
 		fLog.Panic().Err(err).Msg("")
 		return nil
 	}
 	fLog.Info().Msgf("Send request to %s", fwdURL)
-	httpReq, err := http.NewRequest(req.Method, fwdURL.String(), bytes.NewBuffer([]byte(req.Body)))
+	httpReq, err := http.NewRequest(req.Method, fwdURL.String(), bytes.NewBuffer(req.forwardBody()))
 	if err != nil {
 		fLog.Panic().Err(err)
 		return nil
 	}
 
 	if len(req.Headers) > 0 {
-		for name, value := range req.Headers {
-			httpReq.Header.Set(name, value)
+		for name, values := range req.Headers {
+			setHeaderValues(httpReq.Header, name, values)
 		}
 	}
 
 	if len(fwd.Headers) > 0 {
-		for name, value := range fwd.Headers {
-			if name == "Host" {
-				fLog.Debug().Msgf("Set host to %s in request", value)
-				httpReq.Host = value
+		for name, values := range fwd.Headers {
+			if name == "Host" && len(values) > 0 {
+				fLog.Debug().Msgf("Set host to %s in request", values[0])
+				httpReq.Host = values[0]
 			} else {
-				httpReq.Header.Set(name, value)
+				setHeaderValues(httpReq.Header, name, values)
 			}
 		}
 	}
@@ -316,6 +547,22 @@ func (f *GzFilter) responseFromHTTPForward(req *ExpectationRequest, fwd *Expecta
 	return f.doHTTPRequest(httpReq)
 }
 
+// forwardBody returns original request body, which isn't decoded
+func (req *IncomingRequest) forwardBody() []byte {
+	if req.rawBody != nil {
+		return req.rawBody
+	}
+	return []byte(req.Body)
+}
+
+// setHeaderValues replaces all values of the header
+func setHeaderValues(header http.Header, name string, values []string) {
+	header.Del(name)
+	for _, value := range values {
+		header.Add(name, value)
+	}
+}
+
 func toCustomHttpResponse(httpResp *http.Response) (*HttpResponse, error) {
 
 	resp := HttpResponse{
@@ -323,7 +570,7 @@ func toCustomHttpResponse(httpResp *http.Response) (*HttpResponse, error) {
 		Headers:  Headers{},
 	}
 	for name, headerLine := range httpResp.Header {
-		resp.Headers[name] = strings.Join(headerLine, ",")
+		resp.Headers[name] = append([]string(nil), headerLine...)
 	}
 
 	body, err := ioutil.ReadAll(httpResp.Body)
@@ -1,54 +1,17 @@
 package expectations
 
 import (
-	"encoding/base64"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
 
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestRunJsTemplate_SimpleJsonEncodedBase64(t *testing.T) {
-	expReq := &ExpectationRequest{
-		Body: `{"a": [
-			{"b": "bv1"}
-			]}`}
-	tmpl := []byte(`
-	var response = {"response": JSON.parse(request.Body)["a"][0]["b"]};
-	JSON.stringify(response);`)
-
-	tmplEncoded := base64.StdEncoding.EncodeToString(tmpl)
-
-	expectedOutput := `{"response":"bv1"}`
-
-	// Act
-	res, err := runJsTemplate(tmplEncoded, expReq)
-
-	// Assert
-	assert.Nil(t, err)
-	assert.Equal(t, expectedOutput, string(res))
-}
-
-func TestRunJsTemplate_WrongEncoding(t *testing.T) {
-	expReq := &ExpectationRequest{
-		Body: `{"a": [
-				{"b": "bv1"}
-				]}`}
-
-	tmpl := `"abc"`
-
-	// Act
-	res, err := runJsTemplate(tmpl, expReq)
-
-	// Assert
-	assert.NotNil(t, err)
-	assert.Contains(t, err.Error(), "Error decoding from base64 template")
-	assert.Equal(t, "", res)
-}
-
 type mockedRoundTripper struct{}
 
 func (rt *mockedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -74,95 +37,95 @@ func NewMockedGzFilter() *GzFilter {
 	return NewGzFilter(&mockedRoundTripper{}, NewGzStorage())
 }
 
-func TestStringsMatch_EmptyFilter_True(t *testing.T) {
-	assert.True(t, stringsMatch("abc", ""))
+func TestStringMatcherValue_EmptyFilter_True(t *testing.T) {
+	assert.True(t, StringMatcher{Value: ""}.Match("abc"))
 }
 
-func TestStringsMatch_ExistingSubstring_True(t *testing.T) {
-	assert.True(t, stringsMatch("abc", "ab"))
+func TestStringMatcherValue_ExistingSubstring_True(t *testing.T) {
+	assert.True(t, StringMatcher{Value: "ab"}.Match("abc"))
 }
 
-func TestStringsMatch_ExistingRegex_True(t *testing.T) {
-	assert.True(t, stringsMatch("abc", ".b."))
+func TestStringMatcherValue_ExistingRegex_True(t *testing.T) {
+	assert.True(t, StringMatcher{Value: ".b."}.Match("abc"))
 }
 
-func TestStringsMatch_NotExistingSubstring_False(t *testing.T) {
-	assert.False(t, stringsMatch("abc", "zz"))
+func TestStringMatcherValue_NotExistingSubstring_False(t *testing.T) {
+	assert.False(t, StringMatcher{Value: "zz"}.Match("abc"))
 }
 
-func TestStringsMatch_NotExistingRegex_False(t *testing.T) {
-	assert.False(t, stringsMatch("abc", ".z."))
+func TestStringMatcherValue_NotExistingRegex_False(t *testing.T) {
+	assert.False(t, StringMatcher{Value: ".z."}.Match("abc"))
 }
 
-func TestStringsMatch_MultilineBody_True(t *testing.T) {
-	assert.True(t, stringsMatch("a\nb", "a.b"))
+func TestStringMatcherValue_MultilineBody_True(t *testing.T) {
+	assert.True(t, StringMatcher{Value: "a.b"}.Match("a\nb"))
 }
 
 func TestExpectationsMatch_EmptyRequestEmptyFilter_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{},
+		&IncomingRequest{},
 		&ExpectationRequest{}))
 }
 
 func TestExpectationsMatch_MethodsAreEq_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Method: "POST"},
-		&ExpectationRequest{Method: "POST"}))
+		&IncomingRequest{Method: "POST"},
+		&ExpectationRequest{Method: StringMatcher{Value: "POST"}}))
 }
 
 func TestExpectationsMatch_PathsAreEq_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Path: "/path"},
-		&ExpectationRequest{Path: "/path"}))
+		&IncomingRequest{Path: "/path"},
+		&ExpectationRequest{Path: StringMatcher{Value: "/path"}}))
 }
 
 func TestExpectationsMatch_MethodsNotEqAndPathsAreEq_False(t *testing.T) {
 	assert.False(t, expectationsMatch(
-		&ExpectationRequest{Method: "GET", Path: "/path"},
-		&ExpectationRequest{Method: "POST", Path: "/path"}))
+		&IncomingRequest{Method: "GET", Path: "/path"},
+		&ExpectationRequest{Method: StringMatcher{Value: "POST"}, Path: StringMatcher{Value: "/path"}}))
 }
 
 func TestExpectationsMatch_HeadersAreEq_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}},
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}}))
+		&IncomingRequest{Headers: Headers{"h1": {"hv1"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"h1": {{Value: "hv1"}}}}))
 }
 
 func TestExpectationsMatch_HeadersAreEqDifferentCase_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}},
-		&ExpectationRequest{Headers: Headers{"H1": "hv1"}}))
+		&IncomingRequest{Headers: Headers{"h1": {"hv1"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"H1": {{Value: "hv1"}}}}))
 }
 
 func TestExpectationsMatch_HeaderNotEq_False(t *testing.T) {
 	result := expectationsMatch(
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}},
-		&ExpectationRequest{Headers: Headers{"h2": "hv2"}})
+		&IncomingRequest{Headers: Headers{"h1": {"hv1"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"h2": {{Value: "hv2"}}}})
 	assert.False(t, result)
 }
 
 func TestExpectationsMatch_HeaderValueNotEq_False(t *testing.T) {
 	assert.False(t, expectationsMatch(
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}},
-		&ExpectationRequest{Headers: Headers{"h1": "hv2"}}))
+		&IncomingRequest{Headers: Headers{"h1": {"hv1"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"h1": {{Value: "hv2"}}}}))
 }
 
 func TestExpectationsMatch_NoHeaderinReq_False(t *testing.T) {
 	assert.False(t, expectationsMatch(
-		&ExpectationRequest{},
-		&ExpectationRequest{Headers: Headers{"h2": "hv2"}}))
+		&IncomingRequest{},
+		&ExpectationRequest{Headers: ValuesMatchers{"h2": {{Value: "hv2"}}}}))
 }
 
 func TestExpectationsMatch_NoHeaderInFilter_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Headers: Headers{"h1": "hv1"}},
+		&IncomingRequest{Headers: Headers{"h1": {"hv1"}}},
 		&ExpectationRequest{}))
 }
 
 func TestExpectationsMatch_BodysEq_True(t *testing.T) {
 	assert.True(t, expectationsMatch(
-		&ExpectationRequest{Body: "body"},
-		&ExpectationRequest{Body: "body"}))
+		&IncomingRequest{Body: "body"},
+		&ExpectationRequest{Body: StringMatcher{Value: "body"}}))
 }
 
 func httpNewRequestMust(method, url string, body io.Reader) *http.Request {
@@ -183,7 +146,7 @@ func TestGzFilter_ApplyForward_HostAndHeadersAreUpdated(t *testing.T) {
 		Forward: &ExpectationForward{
 			Scheme:  "https",
 			Host:    "localhost_fwd",
-			Headers: Headers{"Host": "fwd_host", "h_req": "hv_fwd", "h_fwd": "hv_fwd"},
+			Headers: Headers{"Host": {"fwd_host"}, "h_req": {"hv_fwd"}, "h_fwd": {"hv_fwd"}},
 		},
 	}
 
@@ -201,6 +164,233 @@ func TestGzFilter_ApplyForward_HostAndHeadersAreUpdated(t *testing.T) {
 	assert.Contains(t, respBody, "Host:fwd_host")
 	assert.Contains(t, respBody, "H_req:hv_fwd")
 	assert.Contains(t, respBody, "H_fwd:hv_fwd")
-	assert.Equal(t, "hv_fwd", resp.Headers["H_req"])
-	assert.Equal(t, "hv_fwd", resp.Headers["H_fwd"])
+	assert.Equal(t, []string{"hv_fwd"}, resp.Headers["H_req"])
+	assert.Equal(t, []string{"hv_fwd"}, resp.Headers["H_fwd"])
+}
+
+func TestExpectationsMatch_HeaderOneOfValues_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Headers: Headers{"Accept": {"text/html", "application/json"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"accept": {{Equals: "application/json"}}}}))
+}
+
+func TestExpectationsMatch_HeaderJoinedValues_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Headers: Headers{"h1": {"hv1", "hv2"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"h1": {{Value: "hv1,hv2"}}}}))
+}
+
+func TestExpectationsMatch_HeaderNotRepeated_False(t *testing.T) {
+	exp := &ExpectationRequest{Headers: ValuesMatchers{"X-Env": {{Not: &StringMatcher{Equals: "prod"}}}}}
+
+	assert.False(t, expectationsMatch(&IncomingRequest{Headers: Headers{"X-Env": {"prod"}}}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Headers: Headers{"X-Env": {"dev", "prod"}}}, exp))
+	assert.True(t, expectationsMatch(&IncomingRequest{Headers: Headers{"X-Env": {"dev", "test"}}}, exp))
+}
+
+func TestExpectationsMatch_HeaderJoinedValuesWithOperators_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Headers: Headers{"h1": {"hv1", "hv2"}}},
+		&ExpectationRequest{Headers: ValuesMatchers{"h1": {{Equals: "hv1,hv2"}}}}))
+}
+
+func TestGzFilter_ApplyResponse_MultipleHeaderValues(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Response: &ExpectationResponse{
+			HTTPCode: http.StatusOK,
+			Headers:  Headers{"Set-Cookie": {"a=1; Path=/", "b=2; Expires=Wed, 21 Oct 2015 07:28:00 GMT"}},
+		},
+	})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, []string{"a=1; Path=/", "b=2; Expires=Wed, 21 Oct 2015 07:28:00 GMT"}, resp.Headers["Set-Cookie"])
+}
+
+func TestExpectationsMatch_CookiesAnyOrder_True(t *testing.T) {
+	req := httpNewRequestMust("GET", "/", nil)
+	req.Header.Add("Cookie", "theme=dark; session=s-42; lang=en")
+	incoming, err := HttpRequestToIncomingRequest(req)
+	assert.Nil(t, err)
+
+	assert.True(t, expectationsMatch(incoming, &ExpectationRequest{
+		Cookies: ValuesMatchers{
+			"lang":    {{Value: "en"}},
+			"session": {{Prefix: "s-"}},
+			"debug":   {{Absent: true}}}}))
+}
+
+func TestExpectationsMatch_CookieValueNotEq_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Cookies: map[string][]string{"session": {"s-1"}}},
+		&ExpectationRequest{Cookies: ValuesMatchers{"session": {{Equals: "s-2"}}}}))
+}
+
+func TestExpectationsMatch_NoCookieInReq_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{},
+		&ExpectationRequest{Cookies: ValuesMatchers{"session": {{}}}}))
+}
+
+func TestExpectationsMatch_HostWithoutPort_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Host: "api.supplier-a.com:8080", Scheme: "http"},
+		&ExpectationRequest{Host: StringMatcher{Equals: "api.supplier-a.com"}, Scheme: StringMatcher{Equals: "http"}}))
+}
+
+func TestExpectationsMatch_HostNotEq_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Host: "api.supplier-b.com"},
+		&ExpectationRequest{Host: StringMatcher{Equals: "api.supplier-a.com"}}))
+}
+
+func TestExpectationsMatch_HostPlainIsNotSubstring_False(t *testing.T) {
+	exp := &ExpectationRequest{Host: StringMatcher{Value: "api.a.com"}}
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Host: "API.a.com:8080"}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Host: "xapi-a.com.evil"}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Host: "api.a.com.evil"}, exp))
+}
+
+func TestExpectationsMatch_HostOperators_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Host: "eu.api.a.com"},
+		&ExpectationRequest{Host: StringMatcher{Glob: "*.api.a.com"}}))
+}
+
+func TestExpectationsMatch_SchemePlainIsNotSubstring_False(t *testing.T) {
+	exp := &ExpectationRequest{Scheme: StringMatcher{Value: "http"}}
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Scheme: "http"}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Scheme: "https"}, exp))
+}
+
+func TestGzFilter_ApplyForward_IncomingHostIfForwardHostEmpty(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{Key: "k", Forward: &ExpectationForward{Scheme: "https"}})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "http://api.supplier-b.com/request?q=1", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Contains(t, string(resp.Body), "https://api.supplier-b.com/request?q=1 Host:api.supplier-b.com")
+}
+
+func TestGzFilter_ApplyForward_IncomingHostWithoutGozzmockPort(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{Key: "k", Forward: &ExpectationForward{Scheme: "https"}})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "http://api.supplier-b.com:8080/request", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Contains(t, string(resp.Body), "https://api.supplier-b.com/request Host:api.supplier-b.com")
+}
+
+func TestGzFilter_ApplyForward_IncomingHostWithForwardPort(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{Key: "k", Forward: &ExpectationForward{Scheme: "http", Port: "9090"}})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "http://api.supplier-b.com:8080/request", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Contains(t, string(resp.Body), "http://api.supplier-b.com:9090/request Host:api.supplier-b.com:9090")
+}
+
+func newBenchmarkGzFilter(count int) *GzFilter {
+	filter := NewMockedGzFilter()
+	for i := 0; i < count; i++ {
+		filter.Add(Expectation{
+			Key: fmt.Sprintf("k%d", i),
+			Request: &ExpectationRequest{
+				Method:  StringMatcher{Value: "POST"},
+				Path:    StringMatcher{Value: fmt.Sprintf("^/api/v1/resource%d/[0-9]+$", i)},
+				Headers: ValuesMatchers{"Content-Type": {{Regex: "^application/(json|xml)"}}},
+				Body:    StringMatcher{Value: `"id":\s*[0-9]+`},
+			},
+			Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "ok"},
+			Priority: i,
+		})
+	}
+	return filter
+}
+
+func BenchmarkGzFilter_Apply300Expectations(b *testing.B) {
+	filter := newBenchmarkGzFilter(300)
+	body := `{"id": 42}`
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httpNewRequestMust("POST", "/api/v1/resource0/12", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := filter.Apply(req)
+		if resp.HTTPCode != http.StatusOK {
+			b.Fatal(resp.HTTPCode)
+		}
+	}
+}
+
+func BenchmarkExpectationsMatch_Compiled(b *testing.B) {
+	req := &IncomingRequest{Method: "POST", Path: "/api/v1/resource0/12", Body: `{"id": 42}`,
+		Headers: Headers{"Content-Type": {"application/json"}}}
+	exp := newBenchmarkGzFilter(1).GetOrdered()[0].Request
+
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		expectationsMatch(req, exp)
+	}
+}
+
+func BenchmarkExpectationsMatch_NotCompiled(b *testing.B) {
+	req := &IncomingRequest{Method: "POST", Path: "/api/v1/resource0/12", Body: `{"id": 42}`,
+		Headers: Headers{"Content-Type": {"application/json"}}}
+	exp := &ExpectationRequest{
+		Method:  StringMatcher{Value: "POST"},
+		Path:    StringMatcher{Value: "^/api/v1/resource0/[0-9]+$"},
+		Headers: ValuesMatchers{"Content-Type": {{Regex: "^application/(json|xml)"}}},
+		Body:    StringMatcher{Value: `"id":\s*[0-9]+`},
+	}
+
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		expectationsMatch(req, exp)
+	}
+}
+
+func BenchmarkGzFilter_Apply3000Expectations(b *testing.B) {
+	filter := newBenchmarkGzFilter(3000)
+	body := `{"id": 42}`
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httpNewRequestMust("POST", "/api/v1/resource0/12", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := filter.Apply(req)
+		if resp.HTTPCode != http.StatusOK {
+			b.Fatal(resp.HTTPCode)
+		}
+	}
 }
@@ -0,0 +1,141 @@
+package expectations
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+)
+
+// FormPart is a part of multipart/form-data request body
+type FormPart struct {
+	Name        string
+	FileName    string
+	ContentType string
+	Body        string
+}
+
+// FormMatcher is filter for application/x-www-form-urlencoded and multipart/form-data request body.
+// Fields are matched like query parameters, every part filter should pass for at least one part
+type FormMatcher struct {
+	Fields ValuesMatchers    `json:"fields,omitempty"`
+	Parts  []FormPartMatcher `json:"parts,omitempty"`
+}
+
+// FormPartMatcher is filter for a part of multipart/form-data request body
+type FormPartMatcher struct {
+	Name        StringMatcher `json:"name"`
+	FileName    StringMatcher `json:"filename"`
+	ContentType StringMatcher `json:"contentType"`
+	Body        StringMatcher `json:"body"`
+}
+
+// parseForm parses request body according to content type.
+// Returns form fields and parts of multipart body. Fields of multipart body are parts without file name
+func parseForm(contentType string, body string) (map[string][]string, []FormPart, error) {
+	if len(contentType) == 0 {
+		return nil, nil, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		fields, err := url.ParseQuery(body)
+		return fields, nil, err
+	case "multipart/form-data":
+		return parseMultipartForm(params["boundary"], body)
+	}
+	return nil, nil, nil
+}
+
+// parseMultipartForm reads all parts of multipart/form-data body
+func parseMultipartForm(boundary string, body string) (map[string][]string, []FormPart, error) {
+	if len(boundary) == 0 {
+		return nil, nil, fmt.Errorf("multipart/form-data without boundary")
+	}
+
+	fields := map[string][]string{}
+	var parts []FormPart
+	reader := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return fields, parts, nil
+		}
+		if err != nil {
+			return fields, parts, err
+		}
+
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			return fields, parts, err
+		}
+
+		formPart := FormPart{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Body:        string(content),
+		}
+		parts = append(parts, formPart)
+		if len(formPart.FileName) == 0 {
+			fields[formPart.Name] = append(fields[formPart.Name], formPart.Body)
+		}
+	}
+}
+
+// compile compiles matchers of fields and parts
+func (exp *FormMatcher) compile() error {
+	err := exp.Fields.compile()
+	for i := range exp.Parts {
+		part := &exp.Parts[i]
+		for _, matcher := range []*StringMatcher{&part.Name, &part.FileName, &part.ContentType, &part.Body} {
+			if matcherErr := matcher.compile(); err == nil {
+				err = matcherErr
+			}
+		}
+	}
+	return err
+}
+
+// match validates whether the part passes the filter
+func (exp FormPartMatcher) match(part FormPart) bool {
+	return exp.Name.Match(part.Name) &&
+		exp.FileName.Match(part.FileName) &&
+		exp.ContentType.Match(part.ContentType) &&
+		exp.Body.Match(part.Body)
+}
+
+// formMatch validates whether the parsed form passes the filter.
+// Returns name of failed field or part
+func formMatch(req *IncomingRequest, exp *FormMatcher) (string, bool) {
+	if exp == nil {
+		return "", true
+	}
+
+	if name, ok := exp.Fields.Match(req.Form); !ok {
+		return name, false
+	}
+
+	for _, expPart := range exp.Parts {
+		found := false
+		for _, part := range req.FormParts {
+			if expPart.match(part) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return expPart.Name.String(), false
+		}
+	}
+
+	return "", true
+}
@@ -0,0 +1,112 @@
+package expectations
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMultipartRequestMust() *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("amount", "100")
+	writer.WriteField("currency", "EUR")
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="receipt"; filename="receipt.pdf"`)
+	header.Set("Content-Type", "application/pdf")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		panic(err)
+	}
+	part.Write([]byte("%PDF-1.4"))
+	writer.Close()
+
+	req := httpNewRequestMust("POST", "/pay", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestParseForm_URLEncoded(t *testing.T) {
+	// Act
+	fields, parts, err := parseForm("application/x-www-form-urlencoded; charset=utf-8", "card=4111%201111&tag=a&tag=b")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Nil(t, parts)
+	assert.Equal(t, map[string][]string{"card": {"4111 1111"}, "tag": {"a", "b"}}, fields)
+}
+
+func TestParseForm_OtherContentType(t *testing.T) {
+	// Act
+	fields, parts, err := parseForm("application/json", `{"a": 1}`)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Nil(t, fields)
+	assert.Nil(t, parts)
+}
+
+func TestHttpRequestToIncomingRequest_MultipartForm(t *testing.T) {
+	// Act
+	req, err := HttpRequestToIncomingRequest(newMultipartRequestMust())
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{"amount": {"100"}, "currency": {"EUR"}}, req.Form)
+	assert.Equal(t, 3, len(req.FormParts))
+	assert.Equal(t, FormPart{Name: "receipt", FileName: "receipt.pdf", ContentType: "application/pdf", Body: "%PDF-1.4"},
+		req.FormParts[2])
+}
+
+func TestExpectationsMatch_FormFields_True(t *testing.T) {
+	req := httpNewRequestMust("POST", "/pay", strings.NewReader("amount=100&currency=EUR"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	incoming, err := HttpRequestToIncomingRequest(req)
+	assert.Nil(t, err)
+
+	assert.True(t, expectationsMatch(incoming, &ExpectationRequest{
+		Form: &FormMatcher{Fields: ValuesMatchers{"currency": {{Equals: "EUR"}}, "cvv": {{Absent: true}}}}}))
+}
+
+func TestExpectationsMatch_FormParts(t *testing.T) {
+	incoming, err := HttpRequestToIncomingRequest(newMultipartRequestMust())
+	assert.Nil(t, err)
+
+	assert.True(t, expectationsMatch(incoming, &ExpectationRequest{
+		Form: &FormMatcher{
+			Fields: ValuesMatchers{"amount": {{Equals: "100"}}},
+			Parts: []FormPartMatcher{{
+				Name:        StringMatcher{Equals: "receipt"},
+				FileName:    StringMatcher{Glob: "*.pdf"},
+				ContentType: StringMatcher{Equals: "application/pdf"},
+				Body:        StringMatcher{Prefix: "%PDF"}}}}}))
+
+	assert.False(t, expectationsMatch(incoming, &ExpectationRequest{
+		Form: &FormMatcher{
+			Parts: []FormPartMatcher{{Name: StringMatcher{Equals: "receipt"}, FileName: StringMatcher{Glob: "*.png"}}}}}))
+}
+
+func TestGzFilter_ApplyResponse_FormInJsTemplate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Response: &ExpectationResponse{
+			HTTPCode:   http.StatusOK,
+			JsTemplate: base64.StdEncoding.EncodeToString([]byte(`form.amount[0] + " " + form.currency[0]`)),
+		},
+	})
+
+	// Act
+	resp := filter.Apply(newMultipartRequestMust())
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, "100 EUR", string(resp.Body))
+}
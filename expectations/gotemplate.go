@@ -0,0 +1,146 @@
+package expectations
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// goTemplateData is context of Go response template, it has the same objects as JS template
+type goTemplateData struct {
+	Request    *IncomingRequest
+	PathParams map[string]string
+	Form       map[string][]string
+	GraphQL    *GraphQLRequest
+}
+
+// goTemplateFuncs are helpers which don't depend on incoming request
+var goTemplateFuncs = template.FuncMap{
+	"now":          templateNow,
+	"uuid":         templateUUID,
+	"random":       templateRandom,
+	"base64":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"base64Decode": templateBase64Decode,
+	"urlEncode":    url.QueryEscape,
+	"json":         templateJSON,
+}
+
+// compileGoTemplate parses Go response template. Request helpers are bound when template is executed
+func compileGoTemplate(tmpl string) (*template.Template, error) {
+	parsed, err := template.New("response").Funcs(goTemplateFuncs).Funcs(goTemplateRequestFuncs(&IncomingRequest{})).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing template %s \n %s", tmpl, err.Error())
+	}
+	return parsed, nil
+}
+
+// runGoTemplate creates response body based on Go template of the response and incoming request
+func (exp *ExpectationResponse) runGoTemplate(req *IncomingRequest) (string, error) {
+	tmpl, err := exp.template, exp.templateErr
+	if !exp.compiled {
+		tmpl, err = compileGoTemplate(exp.Template)
+	}
+	if err != nil {
+		return "", err
+	}
+	return runGoTemplate(tmpl, req)
+}
+
+// runGoTemplate creates response body based on parsed Go template and incoming request
+func runGoTemplate(tmpl *template.Template, req *IncomingRequest) (string, error) {
+	bound, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	bound.Funcs(goTemplateRequestFuncs(req))
+
+	data := goTemplateData{Request: req, PathParams: req.PathParams, Form: req.Form, GraphQL: req.GraphQL}
+	if data.PathParams == nil {
+		data.PathParams = map[string]string{}
+	}
+	if data.Form == nil {
+		data.Form = map[string][]string{}
+	}
+
+	var buf bytes.Buffer
+	if err := bound.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Error running template \n %s", err.Error())
+	}
+	return buf.String(), nil
+}
+
+// goTemplateRequestFuncs are helpers which read the incoming request
+func goTemplateRequestFuncs(req *IncomingRequest) template.FuncMap {
+	return template.FuncMap{
+		// jsonPath returns the first value selected from JSON request body or nothing
+		"jsonPath": func(path string) (interface{}, error) {
+			var doc interface{}
+			if err := json.Unmarshal([]byte(req.Body), &doc); err != nil {
+				return nil, fmt.Errorf("request body isn't JSON: %s", err.Error())
+			}
+			values, err := jsonPathSelect(doc, path)
+			if err != nil || len(values) == 0 {
+				return nil, err
+			}
+			return values[0], nil
+		},
+		"query": func(name string) string {
+			return req.Query.Get(name)
+		},
+		"header": func(name string) string {
+			if values := req.Headers.Values(name); len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		},
+	}
+}
+
+// templateNow returns current time in RFC3339 format or in particular layout
+func templateNow(layout ...string) string {
+	if len(layout) > 0 {
+		return time.Now().Format(layout[0])
+	}
+	return time.Now().Format(time.RFC3339)
+}
+
+// templateUUID returns random UUID version 4
+func templateUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// templateRandom returns random integer from min to max inclusive
+func templateRandom(min int, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf("random max %d is less than min %d", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max-min)+1))
+	if err != nil {
+		return 0, err
+	}
+	return min + int(n.Int64()), nil
+}
+
+// templateBase64Decode decodes base64 string
+func templateBase64Decode(s string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	return string(decoded), err
+}
+
+// templateJSON encodes value as JSON
+func templateJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
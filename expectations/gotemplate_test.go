@@ -0,0 +1,102 @@
+package expectations
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseAndRunGoTemplate(tmpl string, req *IncomingRequest) (string, error) {
+	parsed, err := compileGoTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+	return runGoTemplate(parsed, req)
+}
+
+func TestRunGoTemplate_RequestHelpers(t *testing.T) {
+	req := &IncomingRequest{
+		Method:     "POST",
+		Body:       `{"booking": {"id": 42, "passengers": [{"name": "John"}]}}`,
+		Query:      map[string][]string{"lang": {"en"}},
+		Headers:    Headers{"X-Correlation-Id": {"c-1"}},
+		PathParams: map[string]string{"id": "7"},
+	}
+
+	// Act
+	res, err := parseAndRunGoTemplate(
+		`{{.Request.Method}} {{jsonPath "$.booking.id"}} {{jsonPath "$.booking.passengers[0].name"}} `+
+			`{{query "lang"}} {{header "x-correlation-id"}} {{.PathParams.id}} {{json (jsonPath "$.booking.passengers")}}`, req)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, `POST 42 John en c-1 7 [{"name":"John"}]`, res)
+}
+
+func TestRunGoTemplate_Helpers(t *testing.T) {
+	// Act
+	res, err := parseAndRunGoTemplate(`{{base64 "abc"}} {{base64Decode "YWJj"}} {{urlEncode "a b&c"}} {{random 5 5}} {{now "2006"}} {{uuid}}`,
+		&IncomingRequest{})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^YWJj abc a\+b%26c 5 [0-9]{4} [0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), res)
+}
+
+func TestRunGoTemplate_Errors(t *testing.T) {
+	_, err := parseAndRunGoTemplate(`{{.Request.Method`, &IncomingRequest{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error parsing template")
+
+	_, err = parseAndRunGoTemplate(`{{jsonPath "$.id"}}`, &IncomingRequest{Body: "text"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error running template")
+}
+
+func TestRunGoTemplate_Concurrent(t *testing.T) {
+	parsed, err := compileGoTemplate(`{{query "n"}}`)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for _, n := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(n string) {
+			defer wg.Done()
+			res, err := runGoTemplate(parsed, &IncomingRequest{Query: map[string][]string{"n": {n}}})
+			assert.Nil(t, err)
+			assert.Equal(t, n, res)
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestGzFilter_ApplyResponse_GoTemplate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:     "k",
+		Request: &ExpectationRequest{Path: StringMatcher{Value: "/bookings/{id}"}},
+		Response: &ExpectationResponse{
+			HTTPCode: http.StatusOK,
+			Template: `{"id": "{{.PathParams.id}}", "name": "{{jsonPath "$.name"}}"}`,
+		},
+	})
+	filter.Add(Expectation{
+		Key:      "invalid",
+		Request:  &ExpectationRequest{Path: StringMatcher{Equals: "/invalid"}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Template: `{{.Unknown}}`},
+	})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("POST", "/bookings/12", strings.NewReader(`{"name": "John"}`)))
+	respInvalid := filter.Apply(httpNewRequestMust("POST", "/invalid", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, `{"id": "12", "name": "John"}`, string(resp.Body))
+	assert.Equal(t, http.StatusInternalServerError, respInvalid.HTTPCode)
+	assert.Contains(t, string(respInvalid.Body), "Error running template")
+}
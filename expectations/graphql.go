@@ -0,0 +1,186 @@
+package expectations
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// GraphQLRequest is GraphQL operation parsed from JSON body or from query string of GET request
+type GraphQLRequest struct {
+	Query         string
+	OperationName string
+	// OperationType is query, mutation or subscription
+	OperationType string
+	Variables     map[string]interface{}
+}
+
+// GraphQLMatcher is filter for GraphQL requests.
+// Variables are matched like JSON body in subset mode with regex leaves: request may have extra variables
+type GraphQLMatcher struct {
+	OperationName StringMatcher   `json:"operationName"`
+	OperationType StringMatcher   `json:"operationType"`
+	Query         StringMatcher   `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+
+	// variables is compiled filter of variables, it's set by compile
+	compiled  bool
+	variables *JSONBodyMatcher
+}
+
+// graphQLBody is JSON body of GraphQL request
+type graphQLBody struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// parseGraphQL parses GraphQL operation from query string of GET request,
+// from JSON body or from application/graphql body. Returns nil if request isn't GraphQL
+func parseGraphQL(method string, query url.Values, contentType string, body string) *GraphQLRequest {
+	var gql graphQLBody
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case method == http.MethodGet && len(query.Get("query")) > 0:
+		gql.Query = query.Get("query")
+		gql.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); len(variables) > 0 {
+			if err := json.Unmarshal([]byte(variables), &gql.Variables); err != nil {
+				return nil
+			}
+		}
+	case mediaType == "application/graphql":
+		gql.Query = body
+		gql.OperationName = query.Get("operationName")
+	case mediaType == "application/json" && strings.HasPrefix(strings.TrimSpace(body), "{"):
+		if err := json.Unmarshal([]byte(body), &gql); err != nil || len(gql.Query) == 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	req := &GraphQLRequest{Query: gql.Query, OperationName: gql.OperationName, Variables: gql.Variables}
+	if req.Variables == nil {
+		req.Variables = map[string]interface{}{}
+	}
+	req.OperationType, req.OperationName = graphQLOperation(gql.Query, gql.OperationName)
+	return req
+}
+
+// graphQLToken matches names, punctuation, strings and comments of GraphQL document
+var graphQLToken = regexp.MustCompile(`"""(?s:.*?)"""|"(?:[^"\\]|\\.)*"|#[^\n]*|[_A-Za-z][_0-9A-Za-z]*|[{}]|[^\s,{}"#_A-Za-z]+`)
+
+// graphQLOperation returns type and name of operation in GraphQL document.
+// If name isn't set, the first operation is used. Shorthand "{ ... }" is a query
+func graphQLOperation(document string, name string) (string, string) {
+	depth := 0
+	keyword := ""
+	firstType, firstName := "", ""
+	found := false
+	tokens := graphQLToken.FindAllString(document, -1)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token == "{":
+			if depth == 0 && keyword == "" && !found {
+				firstType, found = "query", true
+			}
+			depth++
+			keyword = ""
+		case token == "}":
+			depth--
+		case depth > 0 || strings.HasPrefix(token, "#"):
+		case keyword != "":
+			// name, variables or type condition of definition
+		case token == "query" || token == "mutation" || token == "subscription":
+			opName := ""
+			if i+1 < len(tokens) && graphQLName(tokens[i+1]) {
+				opName = tokens[i+1]
+			}
+			if len(name) > 0 && opName == name {
+				return token, name
+			}
+			if !found {
+				firstType, firstName, found = token, opName, true
+			}
+			keyword = token
+		case token == "fragment":
+			keyword = token
+		}
+	}
+
+	if len(name) > 0 {
+		return "", name
+	}
+	return firstType, firstName
+}
+
+// graphQLName returns true if token is a name
+func graphQLName(token string) bool {
+	c := token[0]
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// compile compiles matchers and filter of variables
+func (exp *GraphQLMatcher) compile() error {
+	var err error
+	for _, matcher := range []*StringMatcher{&exp.OperationName, &exp.OperationType, &exp.Query} {
+		if matcherErr := matcher.compile(); err == nil {
+			err = matcherErr
+		}
+	}
+	if len(exp.Variables) > 0 {
+		exp.variables = &JSONBodyMatcher{JSON: exp.Variables, RegexLeaves: true}
+		if varErr := exp.variables.compile(); err == nil {
+			err = varErr
+		}
+	}
+	exp.compiled = true
+	return err
+}
+
+// graphQLMatch validates whether GraphQL operation of the request passes the filter.
+// Returns name of failed field
+func graphQLMatch(req *GraphQLRequest, exp *GraphQLMatcher) (string, bool) {
+	if exp == nil {
+		return "", true
+	}
+	if req == nil {
+		return "request", false
+	}
+
+	if !exp.compiled {
+		compiled := *exp
+		compiled.compile()
+		exp = &compiled
+	}
+
+	if !exp.OperationName.Match(req.OperationName) {
+		return "operationName", false
+	}
+	if !exp.OperationType.Match(req.OperationType) {
+		return "operationType", false
+	}
+	if !exp.Query.Match(req.Query) {
+		return "query", false
+	}
+	if exp.variables != nil {
+		if exp.variables.err != nil ||
+			!jsonValuesMatch(req.Variables, exp.variables.expected, false, exp.variables.regexes) {
+			return "variables", false
+		}
+	}
+	return "", true
+}
+
+// graphQLOperationName returns operation type and name for logs
+func graphQLOperationName(req *GraphQLRequest) string {
+	if req == nil {
+		return "<none>"
+	}
+	return strings.TrimSpace(req.OperationType + " " + req.OperationName)
+}
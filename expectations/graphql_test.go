@@ -0,0 +1,118 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const graphQLTestDocument = `
+# user queries
+fragment UserFields on User { id name }
+query GetUser($id: ID!, $query: String = "mutation") { user(id: $id) { ...UserFields } }
+mutation UpdateUser($id: ID!) { updateUser(id: $id) { id } }
+`
+
+func TestGraphQLOperation(t *testing.T) {
+	opType, opName := graphQLOperation(graphQLTestDocument, "")
+	assert.Equal(t, "query", opType)
+	assert.Equal(t, "GetUser", opName)
+
+	opType, opName = graphQLOperation(graphQLTestDocument, "UpdateUser")
+	assert.Equal(t, "mutation", opType)
+	assert.Equal(t, "UpdateUser", opName)
+
+	opType, opName = graphQLOperation(`{ user { id } }`, "")
+	assert.Equal(t, "query", opType)
+	assert.Equal(t, "", opName)
+
+	opType, opName = graphQLOperation(`subscription { events { id } }`, "")
+	assert.Equal(t, "subscription", opType)
+	assert.Equal(t, "", opName)
+}
+
+func TestParseGraphQL_JSONBody(t *testing.T) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"query":         graphQLTestDocument,
+		"operationName": "UpdateUser",
+		"variables":     map[string]interface{}{"id": "42"},
+	})
+
+	// Act
+	req := parseGraphQL("POST", url.Values{}, "application/json; charset=utf-8", string(body))
+
+	// Assert
+	assert.NotNil(t, req)
+	assert.Equal(t, "mutation", req.OperationType)
+	assert.Equal(t, "UpdateUser", req.OperationName)
+	assert.Equal(t, map[string]interface{}{"id": "42"}, req.Variables)
+}
+
+func TestParseGraphQL_GetQueryString(t *testing.T) {
+	query := url.Values{"query": {`query GetUser($id: ID!) { user(id: $id) { id } }`}, "variables": {`{"id": 7}`}}
+
+	// Act
+	req := parseGraphQL("GET", query, "", "")
+
+	// Assert
+	assert.NotNil(t, req)
+	assert.Equal(t, "query", req.OperationType)
+	assert.Equal(t, "GetUser", req.OperationName)
+	assert.Equal(t, map[string]interface{}{"id": float64(7)}, req.Variables)
+}
+
+func TestParseGraphQL_NotGraphQL_Nil(t *testing.T) {
+	assert.Nil(t, parseGraphQL("POST", url.Values{}, "application/json", `{"id": 1}`))
+	assert.Nil(t, parseGraphQL("POST", url.Values{}, "text/plain", `query { a }`))
+	assert.Nil(t, parseGraphQL("GET", url.Values{"q": {"a"}}, "", ""))
+}
+
+func TestGraphQLMatch_Variables(t *testing.T) {
+	req := &GraphQLRequest{OperationType: "query", OperationName: "GetUser",
+		Variables: map[string]interface{}{"id": "42", "locale": "en"}}
+
+	_, ok := graphQLMatch(req, &GraphQLMatcher{
+		OperationName: StringMatcher{Equals: "GetUser"},
+		OperationType: StringMatcher{Equals: "query"},
+		Variables:     json.RawMessage(`{"id": "4[0-9]"}`)})
+	name, okOther := graphQLMatch(req, &GraphQLMatcher{Variables: json.RawMessage(`{"id": "1"}`)})
+	nameNil, okNil := graphQLMatch(nil, &GraphQLMatcher{})
+
+	assert.True(t, ok)
+	assert.False(t, okOther)
+	assert.Equal(t, "variables", name)
+	assert.False(t, okNil)
+	assert.Equal(t, "request", nameNil)
+}
+
+func TestGzFilter_Apply_GraphQLVariablesInJsTemplate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Request: &ExpectationRequest{
+			Path:    StringMatcher{Equals: "/graphql"},
+			GraphQL: &GraphQLMatcher{OperationName: StringMatcher{Equals: "GetUser"}}},
+		Response: &ExpectationResponse{
+			HTTPCode:   http.StatusOK,
+			JsTemplate: base64.StdEncoding.EncodeToString([]byte(`JSON.stringify({data: {user: {id: graphql.Variables.id}}})`)),
+		},
+	})
+
+	req := httpNewRequestMust("POST", "/graphql",
+		strings.NewReader(`{"query": "query GetUser($id: ID!) { user(id: $id) { id } }", "variables": {"id": "42"}}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	// Act
+	resp := filter.Apply(req)
+	respOther := filter.Apply(httpNewRequestMust("POST", "/graphql", strings.NewReader(`{"id": "42"}`)))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, `{"data":{"user":{"id":"42"}}}`, string(resp.Body))
+	assert.Equal(t, http.StatusNotImplemented, respOther.HTTPCode)
+}
@@ -0,0 +1,40 @@
+package expectations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// groupsMatch validates whether the incoming request passes anyOf, allOf and not groups of the filter.
+// Returns failed group and reason of failure. Mode is passed to nested filters
+func groupsMatch(req *IncomingRequest, exp *ExpectationRequest, mode mismatchMode) (string, string, bool) {
+	for i := range exp.AllOf {
+		if mismatches := requestMismatches(req, &exp.AllOf[i], mode); len(mismatches) > 0 {
+			return fmt.Sprintf("allOf[%d]", i), mismatches[0].String(), false
+		}
+	}
+
+	if len(exp.AnyOf) > 0 {
+		reasons := make([]string, 0, len(exp.AnyOf))
+		for i := range exp.AnyOf {
+			mismatches := requestMismatches(req, &exp.AnyOf[i], mode)
+			if len(mismatches) == 0 {
+				log.Debug().Str("messagetype", "controllerRequestPassesFilter").Msgf("Match. Request passes anyOf[%d]", i)
+				reasons = nil
+				break
+			}
+			reasons = append(reasons, fmt.Sprintf("[%d] %s", i, mismatches[0]))
+		}
+		if len(reasons) > 0 {
+			return "anyOf", "no filter passes: " + strings.Join(reasons, ", "), false
+		}
+	}
+
+	if exp.Not != nil && len(requestMismatches(req, exp.Not, mode)) == 0 {
+		return "not", "request passes negated filter", false
+	}
+
+	return "", "", true
+}
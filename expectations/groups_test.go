@@ -0,0 +1,91 @@
+package expectations
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupsMatch_AnyOf(t *testing.T) {
+	exp := &ExpectationRequest{AnyOf: []ExpectationRequest{
+		{Method: StringMatcher{Value: "GET"}},
+		{Method: StringMatcher{Value: "HEAD"}},
+	}}
+
+	_, _, okGet := groupsMatch(&IncomingRequest{Method: "GET"}, exp, firstMismatch)
+	_, _, okHead := groupsMatch(&IncomingRequest{Method: "HEAD"}, exp, firstMismatch)
+	group, reason, okPost := groupsMatch(&IncomingRequest{Method: "POST"}, exp, firstMismatch)
+
+	assert.True(t, okGet)
+	assert.True(t, okHead)
+	assert.False(t, okPost)
+	assert.Equal(t, "anyOf", group)
+	assert.Equal(t, "no filter passes: [0] method: request method POST != GET, [1] method: request method POST != HEAD", reason)
+}
+
+func TestGroupsMatch_AllOf(t *testing.T) {
+	exp := &ExpectationRequest{AllOf: []ExpectationRequest{
+		{Path: StringMatcher{Prefix: "/api/"}},
+		{Path: StringMatcher{Glob: "*/items"}},
+	}}
+
+	_, _, ok := groupsMatch(&IncomingRequest{Path: "/api/v1/items"}, exp, firstMismatch)
+	group, _, okOther := groupsMatch(&IncomingRequest{Path: "/api/v1/orders"}, exp, firstMismatch)
+
+	assert.True(t, ok)
+	assert.False(t, okOther)
+	assert.Equal(t, "allOf[1]", group)
+}
+
+func TestGroupsMatch_Not(t *testing.T) {
+	exp := &ExpectationRequest{Not: &ExpectationRequest{Headers: ValuesMatchers{"X-Debug": {{Equals: "1"}}}}}
+
+	_, _, ok := groupsMatch(&IncomingRequest{Headers: Headers{"X-Debug": {"0"}}}, exp, firstMismatch)
+	group, _, okDebug := groupsMatch(&IncomingRequest{Headers: Headers{"X-Debug": {"1"}}}, exp, firstMismatch)
+
+	assert.True(t, ok)
+	assert.False(t, okDebug)
+	assert.Equal(t, "not", group)
+}
+
+func TestExpectationsMatch_NestedGroups(t *testing.T) {
+	var exp ExpectationRequest
+	err := json.Unmarshal([]byte(`{
+		"method": "POST",
+		"anyOf": [
+			{"path": {"equals": "/a"}},
+			{"path": {"equals": "/b"}, "not": {"query": {"debug": {"equals": "1"}}}}
+		]
+	}`), &exp)
+	assert.Nil(t, err)
+	assert.Nil(t, exp.compile())
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Method: "POST", Path: "/a"}, &exp))
+	assert.True(t, expectationsMatch(&IncomingRequest{Method: "POST", Path: "/b"}, &exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Method: "POST", Path: "/b?debug=1", URLPath: "/b",
+		Query: map[string][]string{"debug": {"1"}}}, &exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Method: "GET", Path: "/a"}, &exp))
+}
+
+func TestGzFilter_Apply_AnyOfMethods(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Request: &ExpectationRequest{
+			Path:  StringMatcher{Equals: "/status"},
+			AnyOf: []ExpectationRequest{{Method: StringMatcher{Value: "GET"}}, {Method: StringMatcher{Value: "HEAD"}}}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "up"},
+	})
+
+	// Act
+	respGet := filter.Apply(httpNewRequestMust("GET", "/status", nil))
+	respHead := filter.Apply(httpNewRequestMust("HEAD", "/status", nil))
+	respPost := filter.Apply(httpNewRequestMust("POST", "/status", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, respGet.HTTPCode)
+	assert.Equal(t, http.StatusOK, respHead.HTTPCode)
+	assert.Equal(t, http.StatusNotImplemented, respPost.HTTPCode)
+}
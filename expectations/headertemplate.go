@@ -0,0 +1,105 @@
+package expectations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robertkrimen/otto"
+)
+
+// headerTemplateStart starts JS expression in response header value, e.g. /users/${pathParams.id}
+const headerTemplateStart = "${"
+
+// isHeaderTemplate returns true if any header value contains JS expression
+func isHeaderTemplate(headers Headers) bool {
+	for _, values := range headers {
+		for _, value := range values {
+			if strings.Contains(value, headerTemplateStart) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderHeaders returns copy of headers with JS expressions replaced by their values.
+// Expressions get the same objects as JsTemplate
+func renderHeaders(headers Headers, req *IncomingRequest) (Headers, error) {
+	rendered := Headers{}
+	if !isHeaderTemplate(headers) {
+		for name, values := range headers {
+			rendered[name] = append([]string(nil), values...)
+		}
+		return rendered, nil
+	}
+
+	vm := newJsVM(req, req.PathParams)
+	for name, values := range headers {
+		renderedValues := make([]string, 0, len(values))
+		for _, value := range values {
+			renderedValue, err := interpolateJs(value, vm)
+			if err != nil {
+				return nil, fmt.Errorf("Error rendering header %s \n %s", name, err.Error())
+			}
+			renderedValues = append(renderedValues, renderedValue)
+		}
+		rendered[name] = renderedValues
+	}
+	return rendered, nil
+}
+
+// interpolateJs replaces every ${expression} in value by result of JS expression
+func interpolateJs(value string, vm *otto.Otto) (string, error) {
+	var sb strings.Builder
+	for {
+		start := strings.Index(value, headerTemplateStart)
+		if start < 0 {
+			sb.WriteString(value)
+			return sb.String(), nil
+		}
+		sb.WriteString(value[:start])
+
+		exprStart := start + len(headerTemplateStart)
+		end := jsExpressionEnd(value[exprStart:])
+		if end < 0 {
+			return "", fmt.Errorf("Unclosed expression in %s", value)
+		}
+
+		expr := value[exprStart : exprStart+end]
+		result, err := vm.Run(expr)
+		if err != nil {
+			return "", fmt.Errorf("Error running expression %s \n %s", expr, err.Error())
+		}
+		if !result.IsUndefined() && !result.IsNull() {
+			sb.WriteString(result.String())
+		}
+		value = value[exprStart+end+1:]
+	}
+}
+
+// jsExpressionEnd returns index of "}" which closes expression. Braces in strings and nested braces are skipped
+func jsExpressionEnd(expr string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
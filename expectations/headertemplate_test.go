@@ -0,0 +1,77 @@
+package expectations
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/robertkrimen/otto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsExpressionEnd(t *testing.T) {
+	assert.Equal(t, 13, jsExpressionEnd("pathParams.id}/orders"))
+	assert.Equal(t, 16, jsExpressionEnd(`({a: "}"}).a + 1}`))
+	assert.Equal(t, -1, jsExpressionEnd("pathParams.id"))
+}
+
+func TestInterpolateJs(t *testing.T) {
+	vm := otto.New()
+	vm.Set("pathParams", map[string]string{"id": "12"})
+
+	res, err := interpolateJs("/users/${pathParams.id}/orders/${pathParams.id * 2}", vm)
+	assert.Nil(t, err)
+	assert.Equal(t, "/users/12/orders/24", res)
+
+	res, err = interpolateJs("plain", vm)
+	assert.Nil(t, err)
+	assert.Equal(t, "plain", res)
+
+	_, err = interpolateJs("${pathParams.id", vm)
+	assert.NotNil(t, err)
+
+	_, err = interpolateJs("${unknown.id}", vm)
+	assert.NotNil(t, err)
+}
+
+func TestGzFilter_ApplyResponse_TemplatedHeaders(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:     "k",
+		Request: &ExpectationRequest{Path: StringMatcher{Value: "/users/{id}"}},
+		Response: &ExpectationResponse{
+			HTTPCode: http.StatusCreated,
+			Headers: Headers{
+				"Location":         {"/users/${pathParams.id}"},
+				"X-Correlation-Id": {"${request.Headers['X-Correlation-Id']}"},
+				"Cache-Control":    {"no-cache"},
+			},
+		},
+	})
+
+	req := httpNewRequestMust("PUT", "/users/12", nil)
+	req.Header.Set("X-Correlation-Id", "c-1")
+
+	// Act
+	resp := filter.Apply(req)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, resp.HTTPCode)
+	assert.Equal(t, []string{"/users/12"}, resp.Headers["Location"])
+	assert.Equal(t, []string{"c-1"}, resp.Headers["X-Correlation-Id"])
+	assert.Equal(t, []string{"no-cache"}, resp.Headers["Cache-Control"])
+}
+
+func TestGzFilter_ApplyResponse_HeaderTemplateError(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:      "k",
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Headers: Headers{"X-Id": {"${request.HeaderValues['X-Id'][0]}"}}},
+	})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, resp.HTTPCode)
+	assert.Contains(t, string(resp.Body), "Error rendering header X-Id")
+}
@@ -0,0 +1,145 @@
+package expectations
+
+import (
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// indexPathField is the request path field which is matched by path filter
+type indexPathField int
+
+const (
+	// indexPath is path including query and fragment
+	indexPath indexPathField = iota
+	// indexURLPath is path without query, it's matched by path templates and by path filter with query filter
+	indexURLPath
+)
+
+// indexKey identifies bucket of expectations.
+// Empty method means any method, prefix is literal path prefix of the filter up to the last "/"
+type indexKey struct {
+	method string
+	field  indexPathField
+	prefix string
+}
+
+// expectationIndex groups ordered expectations by method and literal path prefix,
+// so only expectations which may match the request are checked.
+// Expectations without literal method or path prefix, e.g. with regex-based filters, are in buckets checked for every request
+type expectationIndex struct {
+	ordered OrderedExpectations
+	buckets map[indexKey][]int
+}
+
+// newExpectationIndex builds index of expectations ordered by priority
+func newExpectationIndex(ordered OrderedExpectations) *expectationIndex {
+	index := &expectationIndex{ordered: ordered, buckets: map[indexKey][]int{}}
+	for i := 0; i < len(ordered); i++ {
+		key := expectationIndexKey(ordered[i].Request)
+		index.buckets[key] = append(index.buckets[key], i)
+	}
+	return index
+}
+
+// expectationIndexKey returns bucket of expectation filter
+func expectationIndexKey(exp *ExpectationRequest) indexKey {
+	if exp == nil {
+		return indexKey{}
+	}
+
+	key := indexKey{method: methodLiteral(exp.Method)}
+	prefix := exp.Path.literalPrefix()
+	if isPathTemplate(exp.Path.Value) {
+		key.field = indexURLPath
+		prefix = exp.Path.Value[:strings.IndexByte(exp.Path.Value, '{')]
+	} else if len(exp.Query) > 0 {
+		key.field = indexURLPath
+	}
+	key.prefix = prefix[:strings.LastIndexByte(prefix, '/')+1]
+	return key
+}
+
+// candidates returns expectations which may match the request in the same order as in ordered list
+func (index *expectationIndex) candidates(req *IncomingRequest) []Expectation {
+	var positions []int
+	paths := [...]string{indexPath: req.Path, indexURLPath: req.URLPath}
+	for _, method := range []string{"", req.Method} {
+		for field, path := range paths {
+			for _, prefix := range pathPrefixes(path) {
+				key := indexKey{method: method, field: indexPathField(field), prefix: prefix}
+				positions = append(positions, index.buckets[key]...)
+			}
+		}
+		if len(req.Method) == 0 {
+			break
+		}
+	}
+
+	sort.Ints(positions)
+	candidates := make([]Expectation, 0, len(positions))
+	for _, position := range positions {
+		candidates = append(candidates, index.ordered[position])
+	}
+	return candidates
+}
+
+// pathPrefixes returns empty prefix and all prefixes of path which end with "/"
+func pathPrefixes(path string) []string {
+	prefixes := []string{""}
+	for i := 0; i < len(path); i++ {
+		if path[i] == '/' {
+			prefixes = append(prefixes, path[:i+1])
+		}
+	}
+	return prefixes
+}
+
+// methodLiteral returns method which filter requires to be equal or empty string if filter may pass other values
+func methodLiteral(exp StringMatcher) string {
+	if !exp.hasOperators() {
+		return exp.Value
+	}
+	if len(exp.Equals) > 0 && !exp.IgnoreCase {
+		return exp.Equals
+	}
+	return ""
+}
+
+// literalPrefix returns the longest literal string which every value passing the filter starts with
+func (m StringMatcher) literalPrefix() string {
+	prefixes := []string{regexLiteralPrefix(m.Value)}
+	if !m.IgnoreCase {
+		prefixes = append(prefixes, m.Equals, m.Prefix, regexLiteralPrefix(m.Regex))
+		if end := strings.IndexAny(m.Glob, "*?"); end >= 0 {
+			prefixes = append(prefixes, m.Glob[:end])
+		} else {
+			prefixes = append(prefixes, m.Glob)
+		}
+	}
+
+	longest := ""
+	for _, prefix := range prefixes {
+		if len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	return longest
+}
+
+// regexLiteralPrefix returns literal prefix of regex anchored to the beginning of text, e.g. /api/ for ^/api/[0-9]+
+func regexLiteralPrefix(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil || re.Op != syntax.OpConcat || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		sb.WriteString(string(sub.Rune))
+	}
+	return sb.String()
+}
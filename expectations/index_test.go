@@ -0,0 +1,125 @@
+package expectations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexLiteralPrefix(t *testing.T) {
+	assert.Equal(t, "/api/v1/", regexLiteralPrefix("^/api/v1/[0-9]+$"))
+	assert.Equal(t, "/a", regexLiteralPrefix("^/ab?"))
+	assert.Equal(t, "/users", regexLiteralPrefix("^/users"))
+	assert.Equal(t, "", regexLiteralPrefix("/api/v1"))
+	assert.Equal(t, "", regexLiteralPrefix("^/a|^/b"))
+	assert.Equal(t, "", regexLiteralPrefix("(?i)^/api"))
+	assert.Equal(t, "", regexLiteralPrefix("^/api/("))
+}
+
+func TestStringMatcher_LiteralPrefix(t *testing.T) {
+	assert.Equal(t, "/api/v1", StringMatcher{Equals: "/api/v1"}.literalPrefix())
+	assert.Equal(t, "/api/", StringMatcher{Prefix: "/api/", Regex: "^/a"}.literalPrefix())
+	assert.Equal(t, "/files/", StringMatcher{Glob: "/files/*.pdf"}.literalPrefix())
+	assert.Equal(t, "", StringMatcher{Equals: "/api", IgnoreCase: true}.literalPrefix())
+	assert.Equal(t, "", StringMatcher{Contains: "/api"}.literalPrefix())
+	assert.Equal(t, "", StringMatcher{Value: "/api"}.literalPrefix())
+}
+
+func TestExpectationIndexKey(t *testing.T) {
+	assert.Equal(t, indexKey{}, expectationIndexKey(nil))
+	assert.Equal(t, indexKey{method: "GET", prefix: "/api/v1/"},
+		expectationIndexKey(&ExpectationRequest{Method: StringMatcher{Value: "GET"}, Path: StringMatcher{Value: "^/api/v1/items"}}))
+	assert.Equal(t, indexKey{field: indexURLPath, prefix: "/users/"},
+		expectationIndexKey(&ExpectationRequest{Path: StringMatcher{Value: "/users/{id}/orders"}}))
+	assert.Equal(t, indexKey{method: "", field: indexURLPath, prefix: "/"},
+		expectationIndexKey(&ExpectationRequest{
+			Method: StringMatcher{Regex: "GET|POST"},
+			Path:   StringMatcher{Equals: "/search"},
+			Query:  ValuesMatchers{"q": {{Value: "a"}}}}))
+}
+
+func TestGzStorage_GetCandidates_FiltersByMethodAndPath(t *testing.T) {
+	storage := NewGzStorage()
+	storage.Add(Expectation{Key: "get", Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "GET"}, Path: StringMatcher{Equals: "/api/items"}}})
+	storage.Add(Expectation{Key: "post", Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "POST"}, Path: StringMatcher{Equals: "/api/items"}}})
+	storage.Add(Expectation{Key: "other", Request: &ExpectationRequest{
+		Path: StringMatcher{Prefix: "/other/"}}})
+	storage.Add(Expectation{Key: "regex", Request: &ExpectationRequest{
+		Path: StringMatcher{Value: "items$"}}})
+
+	// Act
+	res := storage.GetCandidates(&IncomingRequest{Method: "GET", Path: "/api/items", URLPath: "/api/items"})
+
+	// Assert
+	var keys []string
+	for _, exp := range res {
+		keys = append(keys, exp.Key)
+	}
+	assert.ElementsMatch(t, []string{"get", "regex"}, keys)
+}
+
+func TestGzStorage_GetCandidates_KeepsOrder(t *testing.T) {
+	storage := NewGzStorage()
+	storage.Add(Expectation{Key: "p1", Priority: 1})
+	storage.Add(Expectation{Key: "p4", Priority: 4, Request: &ExpectationRequest{Path: StringMatcher{Value: "^/api/"}}})
+	storage.Add(Expectation{Key: "p3", Priority: 3, Request: &ExpectationRequest{Path: StringMatcher{Value: "api"}}})
+	storage.Add(Expectation{Key: "p2", Priority: 2, Request: &ExpectationRequest{
+		Method: StringMatcher{Value: "GET"}, Path: StringMatcher{Value: "/users/{id}"}}})
+	storage.Add(Expectation{Key: "p5", Priority: 5, Request: &ExpectationRequest{Method: StringMatcher{Value: "GET"}}})
+
+	// Act
+	res := storage.GetCandidates(&IncomingRequest{Method: "GET", Path: "/api/users/1", URLPath: "/api/users/1"})
+
+	// Assert
+	assert.Equal(t, 4, len(res))
+	assert.Equal(t, "p5", res[0].Key)
+	assert.Equal(t, "p4", res[1].Key)
+	assert.Equal(t, "p3", res[2].Key)
+	assert.Equal(t, "p1", res[3].Key)
+}
+
+func TestGzStorage_GetCandidates_SameMatchAsScan(t *testing.T) {
+	storage := NewGzStorage()
+	requests := []*ExpectationRequest{
+		{Method: StringMatcher{Value: "GET"}, Path: StringMatcher{Value: "^/api/v1/items/[0-9]+$"}},
+		{Method: StringMatcher{Equals: "POST"}, Path: StringMatcher{Glob: "/api/*/items"}},
+		{Method: StringMatcher{Equals: "get", IgnoreCase: true}, Path: StringMatcher{Prefix: "/api/v2/"}},
+		{Path: StringMatcher{Value: "/orders/{id}"}},
+		{Path: StringMatcher{Equals: "/search"}, Query: ValuesMatchers{"q": {{Value: "a"}}}},
+		{Path: StringMatcher{Value: "items"}},
+		nil,
+	}
+	for i, req := range requests {
+		storage.Add(Expectation{Key: string(rune('a' + i)), Priority: i, Request: req})
+	}
+
+	incoming := []*IncomingRequest{
+		{Method: "GET", Path: "/api/v1/items/12", URLPath: "/api/v1/items/12"},
+		{Method: "POST", Path: "/api/v1/items", URLPath: "/api/v1/items"},
+		{Method: "GET", Path: "/api/v2/items?x=/y/", URLPath: "/api/v2/items"},
+		{Method: "DELETE", Path: "/orders/7", URLPath: "/orders/7"},
+		{Method: "GET", Path: "/search?q=a", URLPath: "/search", Query: map[string][]string{"q": {"a"}}},
+		{Method: "PUT", Path: "/x/items", URLPath: "/x/items"},
+	}
+
+	for _, req := range incoming {
+		// Act
+		var scanned, indexed []string
+		ordered := storage.GetOrdered()
+		for i := 0; i < len(ordered); i++ {
+			if expectationsMatch(req, ordered[i].Request) {
+				scanned = append(scanned, ordered[i].Key)
+			}
+		}
+		for _, exp := range storage.GetCandidates(req) {
+			if expectationsMatch(req, exp.Request) {
+				indexed = append(indexed, exp.Key)
+			}
+		}
+
+		// Assert
+		assert.Equal(t, scanned, indexed, req.Path)
+	}
+}
@@ -0,0 +1,176 @@
+package expectations
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+const (
+	// JSONMatchSubset mode requires only fields listed in expectation to match
+	JSONMatchSubset = "subset"
+	// JSONMatchStrict mode requires request body to be equal to expectation
+	JSONMatchStrict = "strict"
+)
+
+// JSONBodyMatcher is filter for request body parsed as JSON. Not negates result of matching.
+// Leaf strings are compared literally unless RegexLeaves is set in subset mode
+type JSONBodyMatcher struct {
+	Mode        string          `json:"mode,omitempty"`
+	JSON        json.RawMessage `json:"json"`
+	Not         bool            `json:"not,omitempty"`
+	RegexLeaves bool            `json:"regexLeaves,omitempty"`
+
+	// expected document and regexes of its leaf strings are set by compile
+	compiled bool
+	expected interface{}
+	regexes  map[string]*regexp.Regexp
+	err      error
+}
+
+// compile decodes expected document and compiles regexes of its leaf strings if they are enabled.
+// Invalid filter never passes
+func (exp *JSONBodyMatcher) compile() error {
+	exp.compiled = true
+	exp.regexes = map[string]*regexp.Regexp{}
+	switch exp.Mode {
+	case "", JSONMatchSubset:
+	case JSONMatchStrict:
+		if exp.RegexLeaves {
+			exp.err = fmt.Errorf("regexLeaves can't be used in %s mode", JSONMatchStrict)
+			return exp.err
+		}
+	default:
+		exp.err = fmt.Errorf("unknown json body mode %s", exp.Mode)
+		return exp.err
+	}
+	if exp.err = json.Unmarshal(exp.JSON, &exp.expected); exp.err != nil {
+		return exp.err
+	}
+	if exp.RegexLeaves {
+		exp.err = compileJSONLeafRegexes(exp.expected, exp.regexes)
+	}
+	return exp.err
+}
+
+// compileJSONLeafRegexes compiles all leaf strings of JSON document. Returns the first invalid regex error
+func compileJSONLeafRegexes(doc interface{}, regexes map[string]*regexp.Regexp) error {
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		for _, child := range value {
+			if err := compileJSONLeafRegexes(child, regexes); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range value {
+			if err := compileJSONLeafRegexes(child, regexes); err != nil {
+				return err
+			}
+		}
+	case string:
+		r, err := regexp.Compile(jsonLeafRegex(value))
+		if err != nil {
+			return err
+		}
+		regexes[value] = r
+	}
+	return nil
+}
+
+// jsonBodyMatch validates whether the request body is JSON matching the filter.
+// Key order and whitespaces are ignored
+func jsonBodyMatch(body string, exp *JSONBodyMatcher) bool {
+	if exp == nil {
+		return true
+	}
+
+	if !exp.compiled {
+		compiled := *exp
+		compiled.compile()
+		exp = &compiled
+	}
+	if exp.err != nil {
+		return false
+	}
+
+	var actual interface{}
+	if err := json.Unmarshal([]byte(body), &actual); err != nil {
+		return exp.Not
+	}
+
+	return jsonValuesMatch(actual, exp.expected, exp.Mode == JSONMatchStrict, exp.regexes) != exp.Not
+}
+
+// jsonValuesMatch compares two decoded JSON values.
+// In subset mode objects may have extra fields and arrays may have extra elements in any order.
+// Regexes are compiled leaf strings of expected value, other leaf strings are compared literally
+func jsonValuesMatch(actual interface{}, expected interface{}, strict bool, regexes map[string]*regexp.Regexp) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok || (strict && len(act) != len(exp)) {
+			return false
+		}
+		for name, expValue := range exp {
+			actValue, ok := act[name]
+			if !ok || !jsonValuesMatch(actValue, expValue, strict, regexes) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		if !strict {
+			return jsonArrayContains(act, exp, regexes)
+		}
+		if len(act) != len(exp) {
+			return false
+		}
+		for i := range exp {
+			if !jsonValuesMatch(act[i], exp[i], strict, regexes) {
+				return false
+			}
+		}
+		return true
+	case string:
+		act, ok := actual.(string)
+		return ok && (act == exp || (regexes[exp] != nil && regexes[exp].MatchString(act)))
+	default:
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// jsonArrayContains validates whether every expected element matches a distinct actual element
+func jsonArrayContains(actual []interface{}, expected []interface{}, regexes map[string]*regexp.Regexp) bool {
+	used := make([]bool, len(actual))
+
+	var match func(i int) bool
+	match = func(i int) bool {
+		if i == len(expected) {
+			return true
+		}
+		for j, act := range actual {
+			if used[j] || !jsonValuesMatch(act, expected[i], false, regexes) {
+				continue
+			}
+			used[j] = true
+			if match(i + 1) {
+				return true
+			}
+			used[j] = false
+		}
+		return false
+	}
+
+	return match(0)
+}
+
+// jsonLeafRegex returns regex which should match the whole leaf string
+func jsonLeafRegex(expr string) string {
+	return "^(?s:" + expr + ")$"
+}
@@ -0,0 +1,103 @@
+package expectations
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONBodyMatch_NilFilter_True(t *testing.T) {
+	assert.True(t, jsonBodyMatch("not a json", nil))
+}
+
+func TestJSONBodyMatch_NotJSONBody_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch("not a json",
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"a": 1}`)}))
+}
+
+func TestJSONBodyMatch_SubsetReorderedKeys_True(t *testing.T) {
+	assert.True(t, jsonBodyMatch(`{"b": "bv",   "a": 1, "c": {"d": true}}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"a":1,"b":"bv"}`)}))
+}
+
+func TestJSONBodyMatch_SubsetDifferentValue_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"a": 1, "b": "bv"}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"a": 2}`)}))
+}
+
+func TestJSONBodyMatch_SubsetMissingField_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"a": 1}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"b": 1}`)}))
+}
+
+func TestJSONBodyMatch_SubsetArrayAnyOrder_True(t *testing.T) {
+	assert.True(t, jsonBodyMatch(`{"a": [{"t": "CHD"}, {"t": "ADT", "n": 1}, 3]}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"a": [3, {"t": "ADT"}]}`)}))
+}
+
+func TestJSONBodyMatch_SubsetArrayElementUsedTwice_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"a": [{"t": "ADT"}]}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"a": [{"t": "ADT"}, {"t": "ADT"}]}`)}))
+}
+
+func TestJSONBodyMatch_SubsetRegexLeaf_True(t *testing.T) {
+	assert.True(t, jsonBodyMatch(`{"id": "ABC-123"}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"id": "[A-Z]+-\\d+"}`), RegexLeaves: true}))
+}
+
+func TestJSONBodyMatch_RegexLeafPartialMatch_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"id": "ABC-123"}`,
+		&JSONBodyMatcher{JSON: json.RawMessage(`{"id": "ABC"}`), RegexLeaves: true}))
+}
+
+func TestJSONBodyMatch_LeafIsLiteralByDefault(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"id": "a"}`, &JSONBodyMatcher{JSON: json.RawMessage(`{"id": "a|b"}`)}))
+	assert.True(t, jsonBodyMatch(`{"id": "a|b"}`, &JSONBodyMatcher{JSON: json.RawMessage(`{"id": "a|b"}`)}))
+	assert.False(t, jsonBodyMatch(`{"id": "1x5"}`,
+		&JSONBodyMatcher{Mode: JSONMatchStrict, JSON: json.RawMessage(`{"id": "1.5"}`)}))
+}
+
+func TestJSONBodyMatcher_CompileInvalid_Error(t *testing.T) {
+	for _, exp := range []*JSONBodyMatcher{
+		{Mode: "Strict", JSON: json.RawMessage(`{"a": 1}`)},
+		{Mode: JSONMatchStrict, JSON: json.RawMessage(`{"a": "1"}`), RegexLeaves: true},
+		{JSON: json.RawMessage(`{"a": "(1"}`), RegexLeaves: true},
+	} {
+		// Act
+		err := exp.compile()
+
+		// Assert
+		assert.NotNil(t, err)
+		assert.False(t, jsonBodyMatch(`{"a": 1}`, exp))
+	}
+}
+
+func TestJSONBodyMatch_StrictEqual_True(t *testing.T) {
+	assert.True(t, jsonBodyMatch(`{ "b": [1, 2], "a": "av" }`,
+		&JSONBodyMatcher{Mode: JSONMatchStrict, JSON: json.RawMessage(`{"a":"av","b":[1,2]}`)}))
+}
+
+func TestJSONBodyMatch_StrictExtraField_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`{"a": "av", "b": 1}`,
+		&JSONBodyMatcher{Mode: JSONMatchStrict, JSON: json.RawMessage(`{"a": "av"}`)}))
+}
+
+func TestJSONBodyMatch_StrictArrayOrder_False(t *testing.T) {
+	assert.False(t, jsonBodyMatch(`[1, 2]`,
+		&JSONBodyMatcher{Mode: JSONMatchStrict, JSON: json.RawMessage(`[2, 1]`)}))
+}
+
+func TestExpectationsMatch_JSONBody_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Body: `{"b": 2, "a": 1}`},
+		&ExpectationRequest{JSONBody: &JSONBodyMatcher{JSON: json.RawMessage(`{"a": 1}`)}}))
+}
+
+func TestJSONBodyMatch_Not(t *testing.T) {
+	exp := &JSONBodyMatcher{JSON: json.RawMessage(`{"a": 1}`), Not: true}
+
+	assert.True(t, jsonBodyMatch(`{"a": 2}`, exp))
+	assert.True(t, jsonBodyMatch(`not a json`, exp))
+	assert.False(t, jsonBodyMatch(`{"a": 1, "b": 2}`, exp))
+}
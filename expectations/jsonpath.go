@@ -0,0 +1,257 @@
+package expectations
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPathMatcher is filter for value selected from JSON request body by JSONPath expression.
+// If neither value nor regex is set, path should select at least one value.
+// Not requires none of selected values to pass the filter
+type JSONPathMatcher struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Regex string          `json:"regex,omitempty"`
+	Not   bool            `json:"not,omitempty"`
+
+	// parsed path, decoded value and compiled regex are set by compile
+	compiled bool
+	steps    []jsonPathStep
+	value    interface{}
+	regex    *regexp.Regexp
+	err      error
+}
+
+type jsonPathStepKind int
+
+const (
+	jsonPathName jsonPathStepKind = iota
+	jsonPathIndex
+	jsonPathWildcard
+)
+
+// jsonPathStep is one parsed segment of JSONPath expression
+type jsonPathStep struct {
+	kind      jsonPathStepKind
+	recursive bool
+	name      string
+	index     int
+}
+
+// parseJSONPath parses JSONPath expression.
+// Supported syntax: $, .name, ['name'], [n], [-n], [*], .*, ..name
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %s should start with $", path)
+	}
+
+	var steps []jsonPathStep
+	for i := 1; i < len(path); {
+		step := jsonPathStep{}
+		switch path[i] {
+		case '.':
+			i++
+			if i < len(path) && path[i] == '.' {
+				step.recursive = true
+				i++
+			}
+			if i < len(path) && path[i] == '[' {
+				break
+			}
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("JSONPath %s has empty name at %d", path, i)
+			}
+			step.name = path[i:end]
+			if step.name == "*" {
+				step.kind = jsonPathWildcard
+			}
+			steps = append(steps, step)
+			i = end
+			continue
+		case '[':
+		default:
+			return nil, fmt.Errorf("JSONPath %s has unexpected symbol at %d", path, i)
+		}
+
+		end := strings.IndexByte(path[i:], ']')
+		if end < 0 {
+			return nil, fmt.Errorf("JSONPath %s has unclosed bracket at %d", path, i)
+		}
+		selector := strings.TrimSpace(path[i+1 : i+end])
+		i += end + 1
+
+		switch {
+		case selector == "*":
+			step.kind = jsonPathWildcard
+		case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+			step.name = selector[1 : len(selector)-1]
+		default:
+			index, err := strconv.Atoi(selector)
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %s has wrong selector [%s]", path, selector)
+			}
+			step.kind = jsonPathIndex
+			step.index = index
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// compile parses path, decodes expected value and compiles regex of the filter.
+// Invalid filter never passes
+func (exp *JSONPathMatcher) compile() error {
+	exp.compiled = true
+	exp.steps, exp.err = parseJSONPath(exp.Path)
+	if exp.err == nil && len(exp.Value) > 0 {
+		exp.err = json.Unmarshal(exp.Value, &exp.value)
+	}
+	if exp.err == nil && len(exp.Regex) > 0 {
+		exp.regex, exp.err = regexp.Compile("(?s)" + exp.Regex)
+	}
+	return exp.err
+}
+
+// jsonPathSelect returns values selected by JSONPath expression from decoded JSON document
+func jsonPathSelect(doc interface{}, path string) ([]interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPathSelectSteps(doc, steps), nil
+}
+
+// jsonPathSelectSteps returns values selected by parsed JSONPath expression from decoded JSON document
+func jsonPathSelectSteps(doc interface{}, steps []jsonPathStep) []interface{} {
+	nodes := []interface{}{doc}
+	for _, step := range steps {
+		if step.recursive {
+			nodes = jsonDescendants(nodes)
+		}
+		var selected []interface{}
+		for _, node := range nodes {
+			selected = append(selected, step.selectChildren(node)...)
+		}
+		nodes = selected
+	}
+
+	return nodes
+}
+
+// selectChildren returns children of the node which are selected by the step
+func (step jsonPathStep) selectChildren(node interface{}) []interface{} {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		if step.kind == jsonPathWildcard {
+			names := make([]string, 0, len(value))
+			for name := range value {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			children := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				children = append(children, value[name])
+			}
+			return children
+		}
+		if child, ok := value[step.name]; ok && step.kind == jsonPathName {
+			return []interface{}{child}
+		}
+	case []interface{}:
+		if step.kind == jsonPathWildcard {
+			return value
+		}
+		if step.kind == jsonPathIndex {
+			index := step.index
+			if index < 0 {
+				index += len(value)
+			}
+			if index >= 0 && index < len(value) {
+				return []interface{}{value[index]}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDescendants returns nodes with all their descendants
+func jsonDescendants(nodes []interface{}) []interface{} {
+	var result []interface{}
+	for _, node := range nodes {
+		result = append(result, node)
+		children := jsonPathStep{kind: jsonPathWildcard}.selectChildren(node)
+		result = append(result, jsonDescendants(children)...)
+	}
+	return result
+}
+
+// jsonPathValueMatch validates whether the selected value passes the filter
+func jsonPathValueMatch(value interface{}, exp *JSONPathMatcher) bool {
+	if len(exp.Value) > 0 && !reflect.DeepEqual(value, exp.value) {
+		return false
+	}
+
+	if len(exp.Regex) > 0 {
+		str, ok := value.(string)
+		if !ok {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return false
+			}
+			str = string(encoded)
+		}
+		if !exp.regex.MatchString(str) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// jsonPathsMatch validates whether the request body passes all JSONPath filters.
+// Returns path of the first failed filter
+func jsonPathsMatch(body string, exps []JSONPathMatcher) (string, bool) {
+	if len(exps) == 0 {
+		return "", true
+	}
+
+	// nothing is selected from body which isn't JSON, so only negated filters pass
+	var doc interface{}
+	isJSON := json.Unmarshal([]byte(body), &doc) == nil
+
+	for i := range exps {
+		exp := &exps[i]
+		if !exp.compiled {
+			compiled := *exp
+			compiled.compile()
+			exp = &compiled
+		}
+		if exp.err != nil {
+			return exp.Path, false
+		}
+		matched := false
+		if isJSON {
+			for _, value := range jsonPathSelectSteps(doc, exp.steps) {
+				if jsonPathValueMatch(value, exp) {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched == exp.Not {
+			return exp.Path, false
+		}
+	}
+
+	return "", true
+}
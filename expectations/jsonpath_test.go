@@ -0,0 +1,160 @@
+package expectations
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonPathTestBody = `{
+	"id": 42,
+	"passengers": [
+		{"type": "ADT", "name": "John"},
+		{"type": "CHD", "name": "Ann", "meta": {"seat": "12A"}}
+	],
+	"contact": {"email": "john@example.com"}
+}`
+
+func jsonDecodeMust(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestJSONPathSelect_DotAndIndex(t *testing.T) {
+	// Act
+	res, err := jsonPathSelect(jsonDecodeMust(jsonPathTestBody), "$.passengers[1].name")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"Ann"}, res)
+}
+
+func TestJSONPathSelect_BracketNameAndNegativeIndex(t *testing.T) {
+	// Act
+	res, err := jsonPathSelect(jsonDecodeMust(jsonPathTestBody), "$['passengers'][-1]['type']")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"CHD"}, res)
+}
+
+func TestJSONPathSelect_Wildcard(t *testing.T) {
+	// Act
+	res, err := jsonPathSelect(jsonDecodeMust(jsonPathTestBody), "$.passengers[*].type")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"ADT", "CHD"}, res)
+}
+
+func TestJSONPathSelect_RecursiveDescent(t *testing.T) {
+	// Act
+	res, err := jsonPathSelect(jsonDecodeMust(jsonPathTestBody), "$..seat")
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"12A"}, res)
+}
+
+func TestJSONPathSelect_WrongPath_Error(t *testing.T) {
+	// Act
+	_, err := jsonPathSelect(jsonDecodeMust(jsonPathTestBody), "passengers[0")
+
+	// Assert
+	assert.NotNil(t, err)
+}
+
+func TestJSONPathsMatch_Value_True(t *testing.T) {
+	path, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.passengers[0].type", Value: json.RawMessage(`"ADT"`)},
+		{Path: "$.id", Value: json.RawMessage(`42`)},
+	})
+
+	assert.True(t, ok)
+	assert.Equal(t, "", path)
+}
+
+func TestJSONPathsMatch_Regex_True(t *testing.T) {
+	_, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.contact.email", Regex: "@example\\.com$"},
+		{Path: "$.id", Regex: "^4"},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestJSONPathsMatch_AnyWildcardValue_True(t *testing.T) {
+	_, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.passengers[*].type", Value: json.RawMessage(`"CHD"`)},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestJSONPathsMatch_Exists_True(t *testing.T) {
+	_, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{{Path: "$.contact"}})
+
+	assert.True(t, ok)
+}
+
+func TestJSONPathsMatch_FailedPathReported(t *testing.T) {
+	path, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.id", Value: json.RawMessage(`42`)},
+		{Path: "$.passengers[0].type", Value: json.RawMessage(`"INF"`)},
+	})
+
+	assert.False(t, ok)
+	assert.Equal(t, "$.passengers[0].type", path)
+}
+
+func TestJSONPathsMatch_MissingPath_False(t *testing.T) {
+	_, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{{Path: "$.missing"}})
+
+	assert.False(t, ok)
+}
+
+func TestJSONPathsMatch_NotJSONBody_False(t *testing.T) {
+	_, ok := jsonPathsMatch("text", []JSONPathMatcher{{Path: "$"}})
+
+	assert.False(t, ok)
+}
+
+func TestJSONPathsMatch_NotJSONBodyNegatedFilter_True(t *testing.T) {
+	_, ok := jsonPathsMatch("text", []JSONPathMatcher{{Path: "$.type", Value: json.RawMessage(`"INF"`), Not: true}})
+
+	assert.True(t, ok)
+}
+
+func TestJSONPathsMatch_NotJSONBody_FirstNotNegatedReported(t *testing.T) {
+	path, ok := jsonPathsMatch("text", []JSONPathMatcher{
+		{Path: "$.type", Not: true},
+		{Path: "$.id"},
+	})
+
+	assert.False(t, ok)
+	assert.Equal(t, "$.id", path)
+}
+
+func TestExpectationsMatch_JSONPath_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Body: jsonPathTestBody},
+		&ExpectationRequest{JSONPath: []JSONPathMatcher{{Path: "$.id", Value: json.RawMessage(`1`)}}}))
+}
+
+func TestJSONPathsMatch_Not(t *testing.T) {
+	_, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.passengers[*].type", Value: json.RawMessage(`"INF"`), Not: true},
+		{Path: "$.missing", Not: true},
+	})
+	assert.True(t, ok)
+
+	path, ok := jsonPathsMatch(jsonPathTestBody, []JSONPathMatcher{
+		{Path: "$.passengers[*].type", Value: json.RawMessage(`"CHD"`), Not: true},
+	})
+	assert.False(t, ok)
+	assert.Equal(t, "$.passengers[*].type", path)
+}
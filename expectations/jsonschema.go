@@ -0,0 +1,108 @@
+package expectations
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// JSONSchemaMatcher is filter for request body which should be valid according to JSON Schema.
+// Schema is set inline or by file path. If RespondWithErrors is set and the rest of filter passes,
+// invalid request gets 400 response with validation errors
+type JSONSchemaMatcher struct {
+	Schema            json.RawMessage `json:"schema,omitempty"`
+	File              string          `json:"file,omitempty"`
+	RespondWithErrors bool            `json:"respondWithErrors,omitempty"`
+
+	// schema is loaded by compile
+	compiled bool
+	schema   *gojsonschema.Schema
+	err      error
+}
+
+// compile loads the schema once, so it isn't read and parsed for every request
+func (exp *JSONSchemaMatcher) compile() error {
+	exp.compiled = true
+	loader, err := exp.jsonSchemaLoader()
+	if err == nil {
+		exp.schema, err = gojsonschema.NewSchema(loader)
+	}
+	exp.err = err
+	return err
+}
+
+// jsonSchemaLoader returns loader of inline schema or schema from file
+func (exp *JSONSchemaMatcher) jsonSchemaLoader() (gojsonschema.JSONLoader, error) {
+	if len(exp.File) == 0 {
+		return gojsonschema.NewBytesLoader(exp.Schema), nil
+	}
+
+	path, err := filepath.Abs(exp.File)
+	if err != nil {
+		return nil, err
+	}
+	return gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(path)), nil
+}
+
+// jsonSchemaValidate returns list of validation errors of request body
+func jsonSchemaValidate(body string, exp *JSONSchemaMatcher) ([]string, error) {
+	if !exp.compiled {
+		compiled := *exp
+		compiled.compile()
+		exp = &compiled
+	}
+	if exp.err != nil {
+		return nil, exp.err
+	}
+
+	result, err := exp.schema.Validate(gojsonschema.NewStringLoader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, resultErr := range result.Errors() {
+		errs = append(errs, resultErr.String())
+	}
+	return errs, nil
+}
+
+// jsonSchemaMatch validates whether the request body is valid according to JSON Schema.
+// Returns validation errors
+func jsonSchemaMatch(body string, exp *JSONSchemaMatcher) ([]string, bool) {
+	if exp == nil {
+		return nil, true
+	}
+
+	errs, err := jsonSchemaValidate(body, exp)
+	if err != nil {
+		return []string{err.Error()}, false
+	}
+	return errs, len(errs) == 0
+}
+
+// jsonSchemaErrorResponse returns 400 response with validation errors
+// if JSON Schema which requires to respond with errors is the only mismatch of the request
+func jsonSchemaErrorResponse(exp *ExpectationRequest, mismatches []mismatch) *HttpResponse {
+	if exp == nil || exp.JSONSchema == nil || !exp.JSONSchema.RespondWithErrors ||
+		len(mismatches) != 1 || mismatches[0].field != "jsonSchema" {
+		return nil
+	}
+
+	errs := mismatches[0].errors
+	body, err := json.Marshal(struct {
+		Errors []string `json:"errors"`
+	}{errs})
+	if err != nil {
+		body = []byte(fmt.Sprint(errs))
+	}
+
+	return &HttpResponse{
+		HTTPCode: http.StatusBadRequest,
+		Body:     body,
+		Headers:  Headers{"Content-Type": {"application/json"}},
+	}
+}
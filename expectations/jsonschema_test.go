@@ -0,0 +1,150 @@
+package expectations
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonSchemaTest = `{
+	"type": "object",
+	"required": ["id", "passengers"],
+	"properties": {
+		"id": {"type": "integer"},
+		"passengers": {"type": "array", "minItems": 1}
+	}
+}`
+
+func TestJSONSchemaMatch_NilFilter_True(t *testing.T) {
+	_, ok := jsonSchemaMatch("not a json", nil)
+
+	assert.True(t, ok)
+}
+
+func TestJSONSchemaMatch_ValidBody_True(t *testing.T) {
+	errs, ok := jsonSchemaMatch(`{"id": 1, "passengers": [{}]}`,
+		&JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest)})
+
+	assert.True(t, ok)
+	assert.Empty(t, errs)
+}
+
+func TestJSONSchemaMatch_InvalidBody_Errors(t *testing.T) {
+	errs, ok := jsonSchemaMatch(`{"id": "1"}`,
+		&JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest)})
+
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(errs))
+}
+
+func TestJSONSchemaMatch_NotJSONBody_False(t *testing.T) {
+	_, ok := jsonSchemaMatch(`text`, &JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest)})
+
+	assert.False(t, ok)
+}
+
+func TestJSONSchemaMatch_SchemaFromFile(t *testing.T) {
+	file := "schema_test.json"
+	err := ioutil.WriteFile(file, []byte(jsonSchemaTest), 0644)
+	assert.Nil(t, err)
+	defer os.Remove(file)
+
+	exp := &JSONSchemaMatcher{File: file}
+
+	_, ok := jsonSchemaMatch(`{"id": 1, "passengers": [{}]}`, exp)
+	assert.True(t, ok)
+
+	_, ok = jsonSchemaMatch(`{"id": 1, "passengers": []}`, exp)
+	assert.False(t, ok)
+}
+
+func TestGzFilter_Apply_JSONSchemaRespondsWithErrors(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Request: &ExpectationRequest{
+			Path:       StringMatcher{Equals: "/booking"},
+			JSONSchema: &JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest), RespondWithErrors: true}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "booked"},
+	})
+
+	// Act
+	respValid := filter.Apply(httpNewRequestMust("POST", "/booking", strings.NewReader(`{"id": 1, "passengers": [{}]}`)))
+	respInvalid := filter.Apply(httpNewRequestMust("POST", "/booking", strings.NewReader(`{"id": 1}`)))
+	respOtherPath := filter.Apply(httpNewRequestMust("POST", "/other", strings.NewReader(`{"id": 1}`)))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, respValid.HTTPCode)
+	assert.Equal(t, "booked", string(respValid.Body))
+
+	assert.Equal(t, http.StatusBadRequest, respInvalid.HTTPCode)
+	assert.Equal(t, []string{"application/json"}, respInvalid.Headers["Content-Type"])
+	assert.Contains(t, string(respInvalid.Body), "passengers is required")
+
+	assert.Equal(t, http.StatusNotImplemented, respOtherPath.HTTPCode)
+}
+
+func TestGzFilter_Apply_JSONSchemaFallsThrough(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:      "strict",
+		Request:  &ExpectationRequest{JSONSchema: &JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest)}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "valid"},
+		Priority: 1,
+	})
+	filter.Add(Expectation{
+		Key:      "fallback",
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "fallback"},
+	})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("POST", "/booking", strings.NewReader(`{"id": 1}`)))
+
+	// Assert
+	assert.Equal(t, "fallback", string(resp.Body))
+}
+
+func TestGzFilter_Apply_JSONSchemaRespondsWithErrorsAfterJsPredicate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Request: &ExpectationRequest{
+			JSONSchema:  &JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest), RespondWithErrors: true},
+			JsPredicate: jsBase64(`request.Headers["X-Role"] == "admin"`)},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "booked"},
+	})
+
+	reqAdmin := httpNewRequestMust("POST", "/booking", strings.NewReader(`{"id": 1}`))
+	reqAdmin.Header.Set("X-Role", "admin")
+
+	// Act
+	respAdmin := filter.Apply(reqAdmin)
+	respOther := filter.Apply(httpNewRequestMust("POST", "/booking", strings.NewReader(`{"id": 1}`)))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, respAdmin.HTTPCode)
+	assert.Contains(t, string(respAdmin.Body), "passengers is required")
+	assert.Equal(t, http.StatusNotImplemented, respOther.HTTPCode)
+}
+
+func TestRequestMismatches_JSONSchemaRespondsWithErrors_RestOfFilterChecked(t *testing.T) {
+	exp := &ExpectationRequest{
+		JSONSchema: &JSONSchemaMatcher{Schema: json.RawMessage(jsonSchemaTest), RespondWithErrors: true},
+		Headers:    ValuesMatchers{"X-Role": {{Value: "admin"}}}}
+	exp.compile()
+
+	// Act
+	mismatches := requestMismatches(&IncomingRequest{Body: `{"id": 1}`}, exp, firstMismatch)
+
+	// Assert
+	assert.Equal(t, 2, len(mismatches))
+	assert.Equal(t, "jsonSchema", mismatches[0].field)
+	assert.Contains(t, mismatches[0].errors[0], "passengers is required")
+	assert.Equal(t, "header X-Role", mismatches[1].field)
+	assert.Nil(t, jsonSchemaErrorResponse(exp, mismatches))
+}
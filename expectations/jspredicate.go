@@ -0,0 +1,86 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// JsPredicateTimeout limits execution time of JS predicate, so a buggy script can't hang request handling
+var JsPredicateTimeout = 100 * time.Millisecond
+
+var errJsPredicateTimeout = errors.New("JS predicate timed out")
+
+// compileJsPredicate decodes and compiles JS predicate. Returns nil script if predicate isn't set
+func compileJsPredicate(encoded string) (*otto.Script, error) {
+	if len(encoded) == 0 {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding from base64 JS predicate %s \n %s", encoded, err.Error())
+	}
+
+	script, err := otto.New().Compile("", string(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling JS predicate %s \n %s", string(decoded), err.Error())
+	}
+	return script, nil
+}
+
+// jsPredicateMatch validates whether JS predicate of the filter returns true for the incoming request.
+// Returns reason of failure
+func jsPredicateMatch(req *IncomingRequest, exp *ExpectationRequest) (string, bool) {
+	if len(exp.JsPredicate) == 0 {
+		return "", true
+	}
+
+	script, err := exp.jsPredicate, exp.jsPredicateErr
+	if !exp.compiled {
+		script, err = compileJsPredicate(exp.JsPredicate)
+	}
+	if err != nil {
+		return err.Error(), false
+	}
+
+	result, err := runJsPredicate(script, req, pathParams(req, exp), JsPredicateTimeout)
+	if err != nil {
+		return err.Error(), false
+	}
+	if !result {
+		return "JS predicate returned false", false
+	}
+	return "", true
+}
+
+// runJsPredicate runs compiled predicate and converts result to boolean.
+// Script is interrupted if it runs longer than timeout
+func runJsPredicate(script *otto.Script, req *IncomingRequest, pathParams map[string]string, timeout time.Duration) (result bool, err error) {
+	vm := newJsVM(req, pathParams)
+	vm.Interrupt = make(chan func(), 1)
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt <- func() {
+			panic(errJsPredicateTimeout)
+		}
+	})
+	defer timer.Stop()
+
+	defer func() {
+		if caught := recover(); caught != nil {
+			if caught != errJsPredicateTimeout {
+				panic(caught)
+			}
+			err = fmt.Errorf("%s after %v", errJsPredicateTimeout, timeout)
+		}
+	}()
+
+	value, err := vm.Run(script)
+	if err != nil {
+		return false, fmt.Errorf("Error running JS predicate \n %s", err.Error())
+	}
+	return value.ToBoolean()
+}
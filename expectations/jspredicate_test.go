@@ -0,0 +1,88 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jsBase64(script string) string {
+	return base64.StdEncoding.EncodeToString([]byte(script))
+}
+
+func TestJsPredicateMatch_NotSet_True(t *testing.T) {
+	_, ok := jsPredicateMatch(&IncomingRequest{}, &ExpectationRequest{})
+
+	assert.True(t, ok)
+}
+
+func TestJsPredicateMatch_SumOfItems(t *testing.T) {
+	exp := &ExpectationRequest{JsPredicate: jsBase64(
+		`JSON.parse(request.Body).items.reduce(function(sum, item) { return sum + item.price; }, 0) > 1000`)}
+	exp.compile()
+
+	_, okBig := jsPredicateMatch(&IncomingRequest{Body: `{"items": [{"price": 600}, {"price": 500}]}`}, exp)
+	reason, okSmall := jsPredicateMatch(&IncomingRequest{Body: `{"items": [{"price": 600}]}`}, exp)
+
+	assert.True(t, okBig)
+	assert.False(t, okSmall)
+	assert.Equal(t, "JS predicate returned false", reason)
+}
+
+func TestJsPredicateMatch_PathParams(t *testing.T) {
+	exp := &ExpectationRequest{Path: StringMatcher{Value: "/users/{id}"}, JsPredicate: jsBase64(`pathParams.id % 2 == 0`)}
+
+	_, okEven := jsPredicateMatch(&IncomingRequest{URLPath: "/users/12"}, exp)
+	_, okOdd := jsPredicateMatch(&IncomingRequest{URLPath: "/users/13"}, exp)
+
+	assert.True(t, okEven)
+	assert.False(t, okOdd)
+}
+
+func TestJsPredicateMatch_InvalidScript_False(t *testing.T) {
+	exp := &ExpectationRequest{JsPredicate: jsBase64(`request.Body ==`)}
+
+	err := exp.compile()
+	reason, ok := jsPredicateMatch(&IncomingRequest{}, exp)
+
+	assert.NotNil(t, err)
+	assert.False(t, ok)
+	assert.Contains(t, reason, "Error compiling JS predicate")
+}
+
+func TestJsPredicateMatch_InfiniteLoop_TimedOut(t *testing.T) {
+	timeout := JsPredicateTimeout
+	JsPredicateTimeout = 10 * time.Millisecond
+	defer func() { JsPredicateTimeout = timeout }()
+
+	// Act
+	reason, ok := jsPredicateMatch(&IncomingRequest{}, &ExpectationRequest{JsPredicate: jsBase64(`while (true) {}`)})
+
+	// Assert
+	assert.False(t, ok)
+	assert.Equal(t, "JS predicate timed out after 10ms", reason)
+}
+
+func TestGzFilter_Apply_JsPredicate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:      "admin",
+		Request:  &ExpectationRequest{JsPredicate: jsBase64(`request.Headers["X-Role"] == "admin"`)},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "admin"},
+	})
+
+	req := httpNewRequestMust("GET", "/", strings.NewReader(""))
+	req.Header.Set("X-Role", "admin")
+
+	// Act
+	resp := filter.Apply(req)
+	respOther := filter.Apply(httpNewRequestMust("GET", "/", strings.NewReader("")))
+
+	// Assert
+	assert.Equal(t, "admin", string(resp.Body))
+	assert.Equal(t, http.StatusNotImplemented, respOther.HTTPCode)
+}
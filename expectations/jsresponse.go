@@ -0,0 +1,83 @@
+package expectations
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/robertkrimen/otto"
+)
+
+// jsTemplateResult is response object which JS template may return instead of body string.
+// Delay is in seconds
+type jsTemplateResult struct {
+	HTTPCode int             `json:"httpcode"`
+	Headers  Headers         `json:"headers"`
+	Body     json.RawMessage `json:"body"`
+	Delay    float64         `json:"delay"`
+}
+
+// runJsTemplateResponse runs template and returns response body.
+// If template returns response object, its code, headers and delay are applied to the response
+func runJsTemplateResponse(encodedTmpl string, req *IncomingRequest, resp *HttpResponse) (string, error) {
+	value, err := evalJsTemplate(encodedTmpl, req)
+	if err != nil {
+		return "", err
+	}
+
+	if !value.IsObject() || value.Class() != "Object" {
+		return value.String(), nil
+	}
+
+	result, err := toJsTemplateResult(value)
+	if err != nil {
+		return "", err
+	}
+
+	if result.HTTPCode > 0 {
+		resp.HTTPCode = result.HTTPCode
+	}
+	if len(result.Headers) > 0 && resp.Headers == nil {
+		resp.Headers = Headers{}
+	}
+	for name, values := range result.Headers {
+		resp.Headers[name] = values
+	}
+	if result.Delay > 0 {
+		time.Sleep(time.Duration(result.Delay * float64(time.Second)))
+	}
+
+	return result.body(), nil
+}
+
+// toJsTemplateResult converts object returned by template to response object
+func toJsTemplateResult(value otto.Value) (*jsTemplateResult, error) {
+	exported, err := value.Export()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading template result \n %s", err.Error())
+	}
+
+	encoded, err := json.Marshal(exported)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading template result \n %s", err.Error())
+	}
+
+	var result jsTemplateResult
+	if err := json.Unmarshal(encoded, &result); err != nil {
+		return nil, fmt.Errorf("Error reading template result %s \n %s", string(encoded), err.Error())
+	}
+	return &result, nil
+}
+
+// body returns string body as is and encodes body of other types as JSON
+func (result *jsTemplateResult) body() string {
+	if len(result.Body) == 0 || string(result.Body) == "null" {
+		return ""
+	}
+
+	var body string
+	if err := json.Unmarshal(result.Body, &body); err == nil {
+		return body
+	}
+	return string(result.Body)
+}
@@ -0,0 +1,139 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunJsTemplateResponse_SimpleJsonEncodedBase64(t *testing.T) {
+	expReq := &IncomingRequest{
+		Body: `{"a": [
+			{"b": "bv1"}
+			]}`}
+	tmpl := []byte(`
+	var response = {"response": JSON.parse(request.Body)["a"][0]["b"]};
+	JSON.stringify(response);`)
+
+	tmplEncoded := base64.StdEncoding.EncodeToString(tmpl)
+
+	expectedOutput := `{"response":"bv1"}`
+
+	// Act
+	res, err := runJsTemplateResponse(tmplEncoded, expReq, &HttpResponse{})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, expectedOutput, string(res))
+}
+
+func TestRunJsTemplateResponse_WrongEncoding(t *testing.T) {
+	expReq := &IncomingRequest{
+		Body: `{"a": [
+				{"b": "bv1"}
+				]}`}
+
+	tmpl := `"abc"`
+
+	// Act
+	res, err := runJsTemplateResponse(tmpl, expReq, &HttpResponse{})
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error decoding from base64 template")
+	assert.Equal(t, "", res)
+}
+
+func TestRunJsTemplateResponse_HeadersJoinedLikeBefore(t *testing.T) {
+	expReq := &IncomingRequest{Headers: Headers{"Accept": {"text/html", "application/json"}}}
+	tmpl := `
+	var accept = request.Headers["Accept"];
+	accept.toLowerCase().indexOf("json") >= 0 ? accept.split(",").length + " " + request.HeaderValues["Accept"][1] : "none";`
+
+	// Act
+	res, err := runJsTemplateResponse(jsBase64(tmpl), expReq, &HttpResponse{})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "2 application/json", res)
+}
+
+func TestRunJsTemplateResponse_PlainString(t *testing.T) {
+	resp := &HttpResponse{HTTPCode: http.StatusOK}
+
+	// Act
+	body, err := runJsTemplateResponse(jsBase64(`"abc" + 1`), &IncomingRequest{}, resp)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "abc1", body)
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+}
+
+func TestRunJsTemplateResponse_ResponseObject(t *testing.T) {
+	resp := &HttpResponse{HTTPCode: http.StatusOK, Headers: Headers{"Content-Type": {"application/json"}}}
+
+	// Act
+	body, err := runJsTemplateResponse(jsBase64(
+		`({httpcode: 404, headers: {"X-Reason": "missing", "Set-Cookie": ["a=1", "b=2"]}, body: {error: "not found"}})`),
+		&IncomingRequest{}, resp)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, `{"error":"not found"}`, body)
+	assert.Equal(t, http.StatusNotFound, resp.HTTPCode)
+	assert.Equal(t, Headers{
+		"Content-Type": {"application/json"},
+		"X-Reason":     {"missing"},
+		"Set-Cookie":   {"a=1", "b=2"},
+	}, resp.Headers)
+}
+
+func TestRunJsTemplateResponse_StringBodyAndDelay(t *testing.T) {
+	resp := &HttpResponse{HTTPCode: http.StatusOK}
+	start := time.Now()
+
+	// Act
+	body, err := runJsTemplateResponse(jsBase64(`({body: "text", delay: 0.05})`), &IncomingRequest{}, resp)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, "text", body)
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Nil(t, resp.Headers)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestRunJsTemplateResponse_InvalidHeaders_Error(t *testing.T) {
+	_, err := runJsTemplateResponse(jsBase64(`({headers: {"X-Count": 5}})`), &IncomingRequest{}, &HttpResponse{})
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error reading template result")
+}
+
+func TestGzFilter_ApplyResponse_JsTemplateDecidesStatus(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:     "k",
+		Request: &ExpectationRequest{Path: StringMatcher{Value: "/users/{id}"}},
+		Response: &ExpectationResponse{
+			HTTPCode: http.StatusOK,
+			JsTemplate: jsBase64(`pathParams.id == "1"
+				? JSON.stringify({id: 1})
+				: {httpcode: 404, body: "user " + pathParams.id + " not found"}`),
+		},
+	})
+
+	// Act
+	respFound := filter.Apply(httpNewRequestMust("GET", "/users/1", nil))
+	respMissing := filter.Apply(httpNewRequestMust("GET", "/users/2", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, respFound.HTTPCode)
+	assert.Equal(t, `{"id":1}`, string(respFound.Body))
+	assert.Equal(t, http.StatusNotFound, respMissing.HTTPCode)
+	assert.Equal(t, "user 2 not found", string(respMissing.Body))
+}
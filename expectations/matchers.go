@@ -0,0 +1,317 @@
+package expectations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StringMatcher is filter for a string field of incoming request.
+// Plain JSON string is stored in Value and keeps legacy behaviour: regex, or substring if it isn't a valid regex.
+// JSON object form sets explicit operators, all of which should pass.
+// Not is a negated matcher, Absent is used in named filters to require parameter to be absent
+type StringMatcher struct {
+	Value      string `json:"-"`
+	Equals     string `json:"equals,omitempty"`
+	Contains   string `json:"contains,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	Glob       string `json:"glob,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	IgnoreCase bool   `json:"ignoreCase,omitempty"`
+	Absent     bool   `json:"absent,omitempty"`
+
+	Not *StringMatcher `json:"not,omitempty"`
+
+	// compiled regexes are set by compile. Nil regex of set operator never matches
+	compiled bool
+	valueRe  *regexp.Regexp
+	regexRe  *regexp.Regexp
+	globRe   *regexp.Regexp
+}
+
+// stringMatcherObject is used to (de)serialize object form of StringMatcher without recursion
+type stringMatcherObject StringMatcher
+
+// UnmarshalJSON accepts plain string as well as object with operators
+func (m *StringMatcher) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		*m = StringMatcher{}
+		return json.Unmarshal(data, &m.Value)
+	}
+
+	var obj stringMatcherObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*m = StringMatcher(obj)
+	return nil
+}
+
+// MarshalJSON writes plain string if no operators are set
+func (m StringMatcher) MarshalJSON() ([]byte, error) {
+	if !m.hasOperators() {
+		return json.Marshal(m.Value)
+	}
+	return json.Marshal(stringMatcherObject(m))
+}
+
+// hasOperators returns true if matcher is set in object form
+func (m StringMatcher) hasOperators() bool {
+	return len(m.Equals) > 0 || len(m.Contains) > 0 || len(m.Regex) > 0 ||
+		len(m.Glob) > 0 || len(m.Prefix) > 0 || m.Absent || m.Not != nil
+}
+
+// IsEmpty returns true if matcher has no conditions and passes any value
+func (m StringMatcher) IsEmpty() bool {
+	return len(m.Value) == 0 && !m.hasOperators()
+}
+
+// String returns human readable representation of matcher for logs. Plain string is returned as is
+func (m StringMatcher) String() string {
+	if !m.hasOperators() {
+		return m.Value
+	}
+	encoded, err := m.MarshalJSON()
+	if err != nil {
+		return m.Value
+	}
+	return string(encoded)
+}
+
+// compile compiles regexes of matcher once, so they aren't compiled for every request
+func (m *StringMatcher) compile() error {
+	var err error
+	if len(m.Value) > 0 {
+		// legacy value which isn't a valid regex is used as substring
+		m.valueRe, _ = regexp.Compile("(?s)" + m.Value)
+	}
+	if len(m.Regex) > 0 {
+		m.regexRe, err = compileRegex(m.Regex, m.IgnoreCase)
+	}
+	if len(m.Glob) > 0 {
+		m.globRe, _ = compileRegex(globToRegex(m.Glob), m.IgnoreCase)
+	}
+	if m.Not != nil {
+		not := *m.Not
+		if notErr := not.compile(); err == nil {
+			err = notErr
+		}
+		m.Not = &not
+	}
+	m.compiled = true
+	return err
+}
+
+// Match validates whether the value passes all conditions of matcher
+func (m StringMatcher) Match(value string) bool {
+	if !m.compiled {
+		m.compile()
+	}
+
+	if m.Absent {
+		return false
+	}
+
+	if len(m.Value) > 0 {
+		if m.valueRe != nil && !m.valueRe.MatchString(value) {
+			return false
+		}
+		if m.valueRe == nil && !strings.Contains(value, m.Value) {
+			return false
+		}
+	}
+
+	normalize := func(s string) string { return s }
+	if m.IgnoreCase {
+		normalize = strings.ToLower
+	}
+
+	if len(m.Equals) > 0 && normalize(value) != normalize(m.Equals) {
+		return false
+	}
+
+	if len(m.Contains) > 0 && !strings.Contains(normalize(value), normalize(m.Contains)) {
+		return false
+	}
+
+	if len(m.Prefix) > 0 && !strings.HasPrefix(normalize(value), normalize(m.Prefix)) {
+		return false
+	}
+
+	if len(m.Regex) > 0 && (m.regexRe == nil || !m.regexRe.MatchString(value)) {
+		return false
+	}
+
+	if len(m.Glob) > 0 && (m.globRe == nil || !m.globRe.MatchString(value)) {
+		return false
+	}
+
+	// invalid negated matcher never passes, otherwise the negation would pass any value
+	if m.Not != nil && (m.Not.invalid() || m.Not.Match(value)) {
+		return false
+	}
+
+	return true
+}
+
+// invalid returns true if regex of compiled matcher or of its negated matcher failed to compile
+func (m StringMatcher) invalid() bool {
+	if len(m.Regex) > 0 && m.regexRe == nil {
+		return true
+	}
+	if len(m.Glob) > 0 && m.globRe == nil {
+		return true
+	}
+	return m.Not != nil && m.Not.invalid()
+}
+
+// compileRegex compiles regex of explicit operator
+func compileRegex(expr string, ignoreCase bool) (*regexp.Regexp, error) {
+	flags := "(?s)"
+	if ignoreCase {
+		flags = "(?si)"
+	}
+	return regexp.Compile(flags + expr)
+}
+
+// globToRegex translates glob pattern to anchored regex. "*" matches any sequence, "?" matches any single symbol
+func globToRegex(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// ValuesMatcher is filter for multi-valued parameter. Every matcher should pass for at least one of values,
+// negated matcher should pass for all values.
+// JSON accepts a single matcher as well as an array of matchers
+type ValuesMatcher []StringMatcher
+
+// UnmarshalJSON accepts single matcher or array of matchers
+func (m *ValuesMatcher) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var list []StringMatcher
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*m = list
+		return nil
+	}
+
+	var single StringMatcher
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*m = ValuesMatcher{single}
+	return nil
+}
+
+// compile compiles all matchers
+func (m ValuesMatcher) compile() error {
+	var err error
+	for i := range m {
+		if matcherErr := m[i].compile(); err == nil {
+			err = matcherErr
+		}
+	}
+	return err
+}
+
+// MarshalJSON writes single matcher without array
+func (m ValuesMatcher) MarshalJSON() ([]byte, error) {
+	if len(m) == 1 {
+		return json.Marshal(m[0])
+	}
+	return json.Marshal([]StringMatcher(m))
+}
+
+// String returns human readable representation of matchers for logs
+func (m ValuesMatcher) String() string {
+	encoded, err := m.MarshalJSON()
+	if err != nil {
+		return fmt.Sprint([]StringMatcher(m))
+	}
+	return string(encoded)
+}
+
+// plain returns true if all matchers are plain strings without operators
+func (m ValuesMatcher) plain() bool {
+	for _, matcher := range m {
+		if matcher.hasOperators() {
+			return false
+		}
+	}
+	return true
+}
+
+// Match validates whether the parameter values pass all matchers.
+// Parameter should be present unless matcher requires it to be absent
+func (m ValuesMatcher) Match(values []string) bool {
+	for _, matcher := range m {
+		if matcher.Absent {
+			if len(values) > 0 {
+				return false
+			}
+			continue
+		}
+
+		if !matcher.matchValues(values) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchValues validates whether the matcher passes for any value or for all values if it is negated.
+// Parameter should be present in both cases
+func (m StringMatcher) matchValues(values []string) bool {
+	if len(values) == 0 {
+		return false
+	}
+
+	all := m.Not != nil
+	for _, value := range values {
+		if m.Match(value) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+// ValuesMatchers are named filters for multi-valued parameters, e.g. for query and headers
+type ValuesMatchers map[string]ValuesMatcher
+
+// compile compiles matchers of all parameters
+func (m ValuesMatchers) compile() error {
+	var err error
+	for _, matcher := range m {
+		if matcherErr := matcher.compile(); err == nil {
+			err = matcherErr
+		}
+	}
+	return err
+}
+
+// Match validates whether the parameters pass all filters
+func (m ValuesMatchers) Match(params map[string][]string) (string, bool) {
+	for name, matcher := range m {
+		if !matcher.Match(params[name]) {
+			return name, false
+		}
+	}
+	return "", true
+}
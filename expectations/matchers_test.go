@@ -0,0 +1,250 @@
+package expectations
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMatcher_UnmarshalPlainString(t *testing.T) {
+	var m StringMatcher
+
+	// Act
+	err := json.Unmarshal([]byte(`"/api/v1"`), &m)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, StringMatcher{Value: "/api/v1"}, m)
+}
+
+func TestStringMatcher_UnmarshalObject(t *testing.T) {
+	var m StringMatcher
+
+	// Act
+	err := json.Unmarshal([]byte(`{"equals": "/api/v1?x=1", "ignoreCase": true}`), &m)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, StringMatcher{Equals: "/api/v1?x=1", IgnoreCase: true}, m)
+}
+
+func TestStringMatcher_MarshalKeepsForm(t *testing.T) {
+	plain, err := json.Marshal(StringMatcher{Value: "abc"})
+	assert.Nil(t, err)
+	assert.Equal(t, `"abc"`, string(plain))
+
+	obj, err := json.Marshal(StringMatcher{Prefix: "/a"})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"prefix":"/a"}`, string(obj))
+}
+
+func TestExpectationRequest_UnmarshalMixedForms(t *testing.T) {
+	var exp ExpectationRequest
+
+	// Act
+	err := json.Unmarshal([]byte(`{
+		"method": "GET",
+		"path": {"glob": "/users/*/orders"},
+		"headers": {"h1": "hv1", "h2": {"contains": "v2"}}}`), &exp)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, StringMatcher{Value: "GET"}, exp.Method)
+	assert.Equal(t, StringMatcher{Glob: "/users/*/orders"}, exp.Path)
+	assert.Equal(t, ValuesMatchers{"h1": {{Value: "hv1"}}, "h2": {{Contains: "v2"}}}, exp.Headers)
+}
+
+func TestStringMatcher_Equals(t *testing.T) {
+	m := StringMatcher{Equals: "/api/v1/price?x=1"}
+
+	assert.True(t, m.Match("/api/v1/price?x=1"))
+	assert.False(t, m.Match("/api/v1/price?x=12"))
+	assert.False(t, m.Match("/API/v1/price?x=1"))
+}
+
+func TestStringMatcher_EqualsIgnoreCase(t *testing.T) {
+	assert.True(t, StringMatcher{Equals: "abc", IgnoreCase: true}.Match("ABC"))
+}
+
+func TestStringMatcher_Contains(t *testing.T) {
+	m := StringMatcher{Contains: "v1.price"}
+
+	assert.True(t, m.Match("/api/v1.price"))
+	assert.False(t, m.Match("/api/v1/price"))
+}
+
+func TestStringMatcher_Prefix(t *testing.T) {
+	m := StringMatcher{Prefix: "/api/", IgnoreCase: true}
+
+	assert.True(t, m.Match("/API/users"))
+	assert.False(t, m.Match("/v2/api/users"))
+}
+
+func TestStringMatcher_Regex(t *testing.T) {
+	m := StringMatcher{Regex: "^/users/\\d+$"}
+
+	assert.True(t, m.Match("/users/12"))
+	assert.False(t, m.Match("/users/ab"))
+}
+
+func TestStringMatcher_InvalidRegex_False(t *testing.T) {
+	assert.False(t, StringMatcher{Regex: "(abc"}.Match("(abc"))
+}
+
+func TestStringMatcher_Glob(t *testing.T) {
+	m := StringMatcher{Glob: "/users/*/orders?"}
+
+	assert.True(t, m.Match("/users/12/orders1"))
+	assert.False(t, m.Match("/users/12/orders"))
+	assert.False(t, m.Match("/v1/users/12/ordersX"))
+}
+
+func TestStringMatcher_AllOperatorsShouldPass(t *testing.T) {
+	m := StringMatcher{Prefix: "/api", Contains: "users"}
+
+	assert.True(t, m.Match("/api/users"))
+	assert.False(t, m.Match("/api/orders"))
+}
+
+func TestStringMatcher_LegacyValue(t *testing.T) {
+	assert.True(t, StringMatcher{Value: ".b."}.Match("abc"))
+	assert.True(t, StringMatcher{Value: "(ab"}.Match("(abc"))
+	assert.True(t, StringMatcher{}.Match("anything"))
+}
+
+func TestExpectationsMatch_MethodOperator_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Method: "get"},
+		&ExpectationRequest{Method: StringMatcher{Equals: "GET", IgnoreCase: true}}))
+}
+
+func TestExpectationsMatch_MethodPlainIsNotSubstring_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Method: "POST"},
+		&ExpectationRequest{Method: StringMatcher{Value: "POS"}}))
+}
+
+func TestValuesMatcher_UnmarshalSingleAndArray(t *testing.T) {
+	var exps ValuesMatchers
+
+	// Act
+	err := json.Unmarshal([]byte(`{"a": "1", "b": ["x", {"prefix": "y"}], "c": {"absent": true}}`), &exps)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, ValuesMatcher{{Value: "1"}}, exps["a"])
+	assert.Equal(t, ValuesMatcher{{Value: "x"}, {Prefix: "y"}}, exps["b"])
+	assert.Equal(t, ValuesMatcher{{Absent: true}}, exps["c"])
+}
+
+func TestValuesMatcher_MultiValued(t *testing.T) {
+	m := ValuesMatcher{{Equals: "b"}, {Equals: "a"}}
+
+	assert.True(t, m.Match([]string{"a", "b", "c"}))
+	assert.False(t, m.Match([]string{"a", "c"}))
+}
+
+func TestValuesMatcher_Present(t *testing.T) {
+	m := ValuesMatcher{{}}
+
+	assert.True(t, m.Match([]string{""}))
+	assert.False(t, m.Match(nil))
+}
+
+func TestValuesMatcher_Absent(t *testing.T) {
+	m := ValuesMatcher{{Absent: true}}
+
+	assert.True(t, m.Match(nil))
+	assert.False(t, m.Match([]string{"1"}))
+}
+
+func TestExpectationsMatch_QueryAnyOrderDecoded_True(t *testing.T) {
+	req, err := HttpRequestToIncomingRequest(
+		httpNewRequestMust("GET", "/api/price?to=AMS&from=New%20York&tag=a&tag=b", nil))
+	assert.Nil(t, err)
+
+	assert.True(t, expectationsMatch(req, &ExpectationRequest{
+		Path: StringMatcher{Equals: "/api/price"},
+		Query: ValuesMatchers{
+			"from":  {{Equals: "New York"}},
+			"tag":   {{Equals: "b"}},
+			"debug": {{Absent: true}}},
+	}))
+}
+
+func TestExpectationsMatch_QueryParameterMissing_False(t *testing.T) {
+	req, err := HttpRequestToIncomingRequest(httpNewRequestMust("GET", "/api/price?to=AMS", nil))
+	assert.Nil(t, err)
+
+	assert.False(t, expectationsMatch(req, &ExpectationRequest{
+		Query: ValuesMatchers{"from": {{}}},
+	}))
+}
+
+func TestStringMatcher_UnmarshalNot(t *testing.T) {
+	var m StringMatcher
+
+	// Act
+	err := json.Unmarshal([]byte(`{"not": {"contains": "X"}}`), &m)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, StringMatcher{Not: &StringMatcher{Contains: "X"}}, m)
+}
+
+func TestStringMatcher_Not(t *testing.T) {
+	m := StringMatcher{Prefix: "/api", Not: &StringMatcher{Contains: "admin"}}
+
+	assert.True(t, m.Match("/api/users"))
+	assert.False(t, m.Match("/api/admin"))
+	assert.False(t, m.Match("/v1/users"))
+}
+
+func TestStringMatcher_NotInvalidRegex_NeverMatches(t *testing.T) {
+	m := StringMatcher{Not: &StringMatcher{Regex: "(admin"}}
+	err := m.compile()
+
+	// Act
+	compiled := m.Match("/api/users")
+	notCompiled := StringMatcher{Not: &StringMatcher{Not: &StringMatcher{Regex: "(admin"}}}.Match("/api/users")
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.False(t, compiled)
+	assert.False(t, notCompiled)
+}
+
+func TestStringMatcher_AbsentNeverMatchesValue(t *testing.T) {
+	assert.False(t, StringMatcher{Absent: true}.Match(""))
+}
+
+func TestValuesMatcher_NotShouldPassForAllValues(t *testing.T) {
+	m := ValuesMatcher{{Not: &StringMatcher{Equals: "x"}}}
+
+	assert.True(t, m.Match([]string{"a", "b"}))
+	assert.False(t, m.Match([]string{"a", "x"}))
+	assert.False(t, m.Match(nil))
+}
+
+func TestExpectationsMatch_HeaderAbsent(t *testing.T) {
+	exp := &ExpectationRequest{Headers: ValuesMatchers{"Authorization": {{Absent: true}}}}
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Headers: Headers{"Accept": {"*/*"}}}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Headers: Headers{"authorization": {"Bearer t"}}}, exp))
+}
+
+func TestExpectationsMatch_HeaderNot(t *testing.T) {
+	exp := &ExpectationRequest{Headers: ValuesMatchers{"Authorization": {{Not: &StringMatcher{Prefix: "Bearer"}}}}}
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Headers: Headers{"Authorization": {"Basic a"}}}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Headers: Headers{"Authorization": {"Bearer t"}}}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{}, exp))
+}
+
+func TestExpectationsMatch_BodyNotContains(t *testing.T) {
+	exp := &ExpectationRequest{Body: StringMatcher{Not: &StringMatcher{Contains: "token"}}}
+
+	assert.True(t, expectationsMatch(&IncomingRequest{Body: "user=a"}, exp))
+	assert.False(t, expectationsMatch(&IncomingRequest{Body: "user=a&token=b"}, exp))
+}
@@ -0,0 +1,95 @@
+package expectations
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pathTemplateVariable matches variables in path template like /users/{id}
+var pathTemplateVariable = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// isPathTemplate returns true if path filter contains variables like /users/{id}/orders/{orderId}
+func isPathTemplate(path string) bool {
+	return pathTemplateVariable.MatchString(path)
+}
+
+// pathTemplateToRegex translates path template to anchored regex with named group per variable.
+// Variable matches a single path segment
+func pathTemplateToRegex(tmpl string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	last := 0
+	for _, loc := range pathTemplateVariable.FindAllStringSubmatchIndex(tmpl, -1) {
+		sb.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		sb.WriteString("(?P<" + tmpl[loc[2]:loc[3]] + ">[^/]+)")
+		last = loc[1]
+	}
+	sb.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// compilePathTemplate compiles path template to regex. Returns nil if path isn't a template
+func compilePathTemplate(path string) (*regexp.Regexp, error) {
+	if !isPathTemplate(path) {
+		return nil, nil
+	}
+	return regexp.Compile(pathTemplateToRegex(path))
+}
+
+// pathTemplateCapture returns variables captured from the path if it matches the compiled template
+func pathTemplateCapture(path string, r *regexp.Regexp) (map[string]string, bool) {
+	match := r.FindStringSubmatch(path)
+	if match == nil {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, name := range r.SubexpNames() {
+		if len(name) > 0 {
+			params[name] = match[i]
+		}
+	}
+	return params, true
+}
+
+// pathTemplateRegex returns compiled path template of the filter or nil if path isn't a template
+func (exp *ExpectationRequest) pathTemplateRegex() *regexp.Regexp {
+	if exp.compiled {
+		return exp.pathTemplate
+	}
+	r, _ := compilePathTemplate(exp.Path.Value)
+	return r
+}
+
+// pathsMatch validates whether the request path passes filter.
+// Path template is matched against the whole path without query
+func pathsMatch(req *IncomingRequest, exp *ExpectationRequest) (string, bool) {
+	if r := exp.pathTemplateRegex(); r != nil {
+		return req.URLPath, r.MatchString(req.URLPath)
+	}
+
+	// path is matched without query if query is filtered separately
+	if len(exp.Query) > 0 {
+		return req.URLPath, exp.Path.Match(req.URLPath)
+	}
+	return req.Path, exp.Path.Match(req.Path)
+}
+
+// pathParams returns variables captured from request path by path template of the filter
+func pathParams(req *IncomingRequest, exp *ExpectationRequest) map[string]string {
+	if exp == nil {
+		return map[string]string{}
+	}
+
+	r := exp.pathTemplateRegex()
+	if r == nil {
+		return map[string]string{}
+	}
+
+	params, ok := pathTemplateCapture(req.URLPath, r)
+	if !ok {
+		return map[string]string{}
+	}
+	return params
+}
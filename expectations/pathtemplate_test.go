@@ -0,0 +1,76 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPathTemplate(t *testing.T) {
+	assert.True(t, isPathTemplate("/users/{id}"))
+	assert.False(t, isPathTemplate("/users/a{2}"))
+	assert.False(t, isPathTemplate("/users/.*"))
+}
+
+func capturePathTemplate(t *testing.T, path string, tmpl string) (map[string]string, bool) {
+	r, err := compilePathTemplate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pathTemplateCapture(path, r)
+}
+
+func TestPathTemplateCapture_CapturesVariables(t *testing.T) {
+	// Act
+	params, ok := capturePathTemplate(t, "/users/12/orders/A-7", "/users/{id}/orders/{orderId}")
+
+	// Assert
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "12", "orderId": "A-7"}, params)
+}
+
+func TestPathTemplateCapture_VariableIsSingleSegment_False(t *testing.T) {
+	_, ok := capturePathTemplate(t, "/users/12/34/orders/1", "/users/{id}/orders/{orderId}")
+
+	assert.False(t, ok)
+}
+
+func TestPathTemplateCapture_LiteralPartsAreNotRegex_False(t *testing.T) {
+	_, ok := capturePathTemplate(t, "/v1x/users/12", "/v1.users/{id}")
+
+	assert.False(t, ok)
+}
+
+func TestExpectationsMatch_PathTemplateIgnoresQuery_True(t *testing.T) {
+	req, err := HttpRequestToIncomingRequest(httpNewRequestMust("GET", "/users/12?x=1", nil))
+	assert.Nil(t, err)
+
+	assert.True(t, expectationsMatch(req, &ExpectationRequest{Path: StringMatcher{Value: "/users/{id}"}}))
+}
+
+func TestExpectationsMatch_PathTemplatePrefixOnly_False(t *testing.T) {
+	assert.False(t, expectationsMatch(
+		&IncomingRequest{Path: "/users/12/orders", URLPath: "/users/12/orders"},
+		&ExpectationRequest{Path: StringMatcher{Value: "/users/{id}"}}))
+}
+
+func TestGzFilter_ApplyResponse_PathParamsInJsTemplate(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key:     "k",
+		Request: &ExpectationRequest{Path: StringMatcher{Value: "/users/{id}/orders/{orderId}"}},
+		Response: &ExpectationResponse{
+			HTTPCode:   http.StatusOK,
+			JsTemplate: base64.StdEncoding.EncodeToString([]byte(`pathParams.id + ":" + request.PathParams.orderId`)),
+		},
+	})
+
+	// Act
+	resp := filter.Apply(httpNewRequestMust("GET", "/users/12/orders/34", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, "12:34", string(resp.Body))
+}
@@ -0,0 +1,112 @@
+package expectations
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// MockDataDir is directory with files of response bodies, bodyFile paths are resolved against it
+var MockDataDir = "."
+
+// contentSniffLen is number of bytes used to detect content type, see http.DetectContentType
+const contentSniffLen = 512
+
+// decodeBodyBase64 decodes binary response body
+func decodeBodyBase64(encoded string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding from base64 body \n %s", err.Error())
+	}
+	return decoded, nil
+}
+
+// dataFilePath resolves path of body file against mock data directory, so it can't point outside of the directory
+func dataFilePath(name string) string {
+	return filepath.Join(MockDataDir, filepath.Clean("/"+name))
+}
+
+// setBinaryBody sets decoded base64 body or file body of the response.
+// File isn't read into memory, it's streamed by WriteBody. Content type is detected if it isn't set in headers
+func (exp *ExpectationResponse) setBinaryBody(resp *HttpResponse) error {
+	if resp.Headers == nil {
+		resp.Headers = Headers{}
+	}
+
+	if len(exp.BodyBase64) > 0 {
+		body, err := exp.bodyBase64, exp.bodyBase64Err
+		if !exp.compiled {
+			body, err = decodeBodyBase64(exp.BodyBase64)
+		}
+		if err != nil {
+			return err
+		}
+		resp.Body = body
+		if len(resp.Headers.Values("Content-Type")) == 0 {
+			resp.Headers["Content-Type"] = []string{http.DetectContentType(body)}
+		}
+		return nil
+	}
+
+	file, err := os.Open(dataFilePath(exp.BodyFile))
+	if err != nil {
+		return fmt.Errorf("Error opening body file %s \n %s", exp.BodyFile, err.Error())
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Error opening body file %s \n %s", exp.BodyFile, err.Error())
+	}
+	if info.IsDir() {
+		file.Close()
+		return fmt.Errorf("Error opening body file %s \n it's a directory", exp.BodyFile)
+	}
+
+	if len(resp.Headers.Values("Content-Type")) == 0 {
+		contentType, err := fileContentType(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("Error reading body file %s \n %s", exp.BodyFile, err.Error())
+		}
+		resp.Headers["Content-Type"] = []string{contentType}
+	}
+	if len(resp.Headers.Values("Content-Length")) == 0 {
+		resp.Headers["Content-Length"] = []string{strconv.FormatInt(info.Size(), 10)}
+	}
+	resp.bodyFile = file
+	resp.bodyFileSize = info.Size()
+	return nil
+}
+
+// fileContentType detects content type by file extension or by the first bytes of the file
+func fileContentType(file *os.File) (string, error) {
+	if contentType := mime.TypeByExtension(filepath.Ext(file.Name())); len(contentType) > 0 {
+		return contentType, nil
+	}
+
+	buf := make([]byte, contentSniffLen)
+	n, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// WriteBody writes response body. File body is streamed from the file opened when response was created,
+// up to the size reported in Content-Length, and the file is closed
+func (resp *HttpResponse) WriteBody(w io.Writer) error {
+	if resp.bodyFile == nil {
+		_, err := w.Write(resp.Body)
+		return err
+	}
+
+	defer resp.bodyFile.Close()
+	_, err := io.Copy(w, io.NewSectionReader(resp.bodyFile, 0, resp.bodyFileSize))
+	return err
+}
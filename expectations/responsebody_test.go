@@ -0,0 +1,136 @@
+package expectations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withMockDataDir(t *testing.T, files map[string][]byte) func() {
+	dir, err := ioutil.TempDir("", "gozzmock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := MockDataDir
+	MockDataDir = dir
+	return func() {
+		MockDataDir = previous
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDataFilePath_StaysInDataDir(t *testing.T) {
+	previous := MockDataDir
+	defer func() { MockDataDir = previous }()
+	MockDataDir = "/data"
+
+	assert.Equal(t, "/data/tickets/1.pdf", dataFilePath("tickets/1.pdf"))
+	assert.Equal(t, "/data/tickets/1.pdf", dataFilePath("/tickets/1.pdf"))
+	assert.Equal(t, "/data/etc/passwd", dataFilePath("../../etc/passwd"))
+}
+
+func TestResponseFromExpectation_BodyBase64_SniffsContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x01\x02")
+	exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyBase64: "iVBORw0KGgoAAQI="}
+	exp.compile()
+
+	// Act
+	resp := responseFromExpectation(exp, &IncomingRequest{})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, resp.HTTPCode)
+	assert.Equal(t, png, resp.Body)
+	assert.Equal(t, []string{"image/png"}, resp.Headers["Content-Type"])
+}
+
+func TestResponseFromExpectation_BodyBase64_KeepsContentTypeHeader(t *testing.T) {
+	exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyBase64: "CgJpZA==",
+		Headers: Headers{"content-type": {"application/x-protobuf"}}}
+
+	// Act
+	resp := responseFromExpectation(exp, &IncomingRequest{})
+
+	// Assert
+	assert.Equal(t, []byte("\n\x02id"), resp.Body)
+	assert.Equal(t, Headers{"content-type": {"application/x-protobuf"}}, resp.Headers)
+}
+
+func TestResponseFromExpectation_BodyBase64Invalid_Returns500(t *testing.T) {
+	exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyBase64: "not base64!"}
+	err := exp.compile()
+
+	// Act
+	resp := responseFromExpectation(exp, &IncomingRequest{})
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.HTTPCode)
+	assert.Contains(t, string(resp.Body), "Error decoding from base64 body")
+}
+
+func TestResponseFromExpectation_BodyFile_StreamsFile(t *testing.T) {
+	pdf := []byte("%PDF-1.4 ticket")
+	defer withMockDataDir(t, map[string][]byte{"ticket.pdf": pdf, "payload": []byte("<html><body>")})()
+
+	for name, contentType := range map[string]string{"ticket.pdf": "application/pdf", "/payload": "text/html; charset=utf-8"} {
+		exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyFile: name}
+
+		// Act
+		resp := responseFromExpectation(exp, &IncomingRequest{})
+		var buf bytes.Buffer
+		err := resp.WriteBody(&buf)
+
+		// Assert
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, resp.HTTPCode, name)
+		assert.Empty(t, resp.Body, name)
+		assert.Equal(t, []string{contentType}, resp.Headers["Content-Type"], name)
+		if name == "ticket.pdf" {
+			assert.Equal(t, pdf, buf.Bytes())
+			assert.Equal(t, []string{"15"}, resp.Headers["Content-Length"])
+		}
+	}
+}
+
+func TestResponseFromExpectation_BodyFileMissing_Returns500(t *testing.T) {
+	defer withMockDataDir(t, nil)()
+	exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyFile: "missing.pdf"}
+
+	// Act
+	resp := responseFromExpectation(exp, &IncomingRequest{})
+	var buf bytes.Buffer
+	resp.WriteBody(&buf)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, resp.HTTPCode)
+	assert.Contains(t, buf.String(), "Error opening body file missing.pdf")
+	assert.Empty(t, resp.Headers["Content-Length"])
+}
+
+func TestHttpResponse_WriteBody_FileChangedAfterHeaders(t *testing.T) {
+	defer withMockDataDir(t, map[string][]byte{"ticket.pdf": []byte("%PDF-1.4 ticket")})()
+	exp := &ExpectationResponse{HTTPCode: http.StatusOK, BodyFile: "ticket.pdf"}
+
+	// Act
+	resp := responseFromExpectation(exp, &IncomingRequest{})
+	ioutil.WriteFile(filepath.Join(MockDataDir, "ticket.pdf"), []byte("%PDF-1.4 ticket with more pages"), 0644)
+	os.Remove(filepath.Join(MockDataDir, "ticket.pdf"))
+	var buf bytes.Buffer
+	err := resp.WriteBody(&buf)
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"15"}, resp.Headers["Content-Length"])
+	assert.Equal(t, 15, buf.Len())
+}
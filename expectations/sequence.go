@@ -0,0 +1,93 @@
+package expectations
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const (
+	// SequenceStop repeats the last response when sequence is exhausted, it's default mode
+	SequenceStop = "stop"
+	// SequenceCycle starts sequence from the first response when it's exhausted
+	SequenceCycle = "cycle"
+	// SequenceFallThrough skips expectation when sequence is exhausted, so the next matching expectation is applied
+	SequenceFallThrough = "fallthrough"
+)
+
+// ResponseSequence is ordered list of responses, every matching request gets the next response.
+// Position is index of the next response, it's shared by all copies of expectation
+type ResponseSequence struct {
+	Responses []ExpectationResponse `json:"responses"`
+	Mode      string                `json:"mode,omitempty"`
+	Position  int                   `json:"position"`
+
+	mu sync.Mutex
+}
+
+// compile compiles responses and validates mode
+func (seq *ResponseSequence) compile() error {
+	var err error
+	for i := range seq.Responses {
+		if respErr := seq.Responses[i].compile(); err == nil {
+			err = respErr
+		}
+	}
+	switch seq.Mode {
+	case "", SequenceStop, SequenceCycle, SequenceFallThrough:
+	default:
+		if err == nil {
+			err = fmt.Errorf("unknown sequence mode %s, %s is used", seq.Mode, SequenceStop)
+		}
+	}
+	return err
+}
+
+// next returns the next response and moves position. Returns nil if sequence is exhausted in fall through mode
+func (seq *ResponseSequence) next() *ExpectationResponse {
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+
+	count := len(seq.Responses)
+	if count == 0 {
+		return nil
+	}
+	if seq.Position < 0 || (seq.Position >= count && seq.Mode == SequenceCycle) {
+		seq.Position = 0
+	}
+	if seq.Position >= count {
+		if seq.Mode == SequenceFallThrough {
+			return nil
+		}
+		seq.Position = count - 1
+	}
+
+	resp := &seq.Responses[seq.Position]
+	switch {
+	case seq.Position < count-1 || seq.Mode == SequenceFallThrough:
+		seq.Position++
+	case seq.Mode == SequenceCycle:
+		seq.Position = 0
+	}
+	return resp
+}
+
+// reset moves position to the first response
+func (seq *ResponseSequence) reset() {
+	seq.mu.Lock()
+	seq.Position = 0
+	seq.mu.Unlock()
+}
+
+// MarshalJSON writes current position of the sequence
+func (seq *ResponseSequence) MarshalJSON() ([]byte, error) {
+	seq.mu.Lock()
+	position := seq.Position
+	seq.mu.Unlock()
+
+	return json.Marshal(struct {
+		Responses []ExpectationResponse `json:"responses"`
+		Mode      string                `json:"mode,omitempty"`
+		Position  int                   `json:"position"`
+	}{seq.Responses, seq.Mode, position})
+}
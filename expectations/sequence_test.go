@@ -0,0 +1,109 @@
+package expectations
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sequenceCodes(seq *ResponseSequence, calls int) []int {
+	var codes []int
+	for i := 0; i < calls; i++ {
+		if resp := seq.next(); resp != nil {
+			codes = append(codes, resp.HTTPCode)
+		} else {
+			codes = append(codes, 0)
+		}
+	}
+	return codes
+}
+
+func TestResponseSequence_Next_Modes(t *testing.T) {
+	responses := []ExpectationResponse{{HTTPCode: 503}, {HTTPCode: 502}, {HTTPCode: 200}}
+
+	assert.Equal(t, []int{503, 502, 200, 200, 200}, sequenceCodes(&ResponseSequence{Responses: responses}, 5))
+	assert.Equal(t, []int{503, 502, 200, 200}, sequenceCodes(&ResponseSequence{Responses: responses, Mode: SequenceStop}, 4))
+	assert.Equal(t, []int{503, 502, 200, 503, 502}, sequenceCodes(&ResponseSequence{Responses: responses, Mode: SequenceCycle}, 5))
+	assert.Equal(t, []int{503, 502, 200, 0, 0}, sequenceCodes(&ResponseSequence{Responses: responses, Mode: SequenceFallThrough}, 5))
+	assert.Equal(t, []int{0}, sequenceCodes(&ResponseSequence{}, 1))
+}
+
+func TestResponseSequence_Next_StartsFromPosition(t *testing.T) {
+	responses := []ExpectationResponse{{HTTPCode: 503}, {HTTPCode: 200}}
+
+	assert.Equal(t, []int{200, 200}, sequenceCodes(&ResponseSequence{Responses: responses, Position: 1}, 2))
+	assert.Equal(t, []int{200, 200}, sequenceCodes(&ResponseSequence{Responses: responses, Position: 7}, 2))
+	assert.Equal(t, []int{503, 200}, sequenceCodes(&ResponseSequence{Responses: responses, Mode: SequenceCycle, Position: 7}, 2))
+	assert.Equal(t, []int{503, 200}, sequenceCodes(&ResponseSequence{Responses: responses, Position: -1}, 2))
+}
+
+func TestResponseSequence_CompileUnknownMode_ReturnsError(t *testing.T) {
+	seq := &ResponseSequence{Responses: []ExpectationResponse{{Template: "{{.Request.Method}}"}}, Mode: "random"}
+
+	// Act
+	err := seq.compile()
+
+	// Assert
+	assert.NotNil(t, err)
+	assert.True(t, seq.Responses[0].compiled)
+}
+
+func TestResponseSequence_MarshalJSON_WritesPosition(t *testing.T) {
+	seq := &ResponseSequence{Responses: []ExpectationResponse{{HTTPCode: 503}, {HTTPCode: 200}}, Mode: SequenceCycle}
+	seq.next()
+
+	// Act
+	data, err := json.Marshal(Expectation{Key: "k", Sequence: seq})
+
+	// Assert
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"mode":"cycle","position":1}`)
+}
+
+func TestGzFilter_Apply_SequenceFallsThroughToNextExpectation(t *testing.T) {
+	filter := NewGzFilter(http.DefaultTransport, NewGzStorage())
+	filter.Add(Expectation{Key: "retry", Priority: 2, Sequence: &ResponseSequence{
+		Responses: []ExpectationResponse{{HTTPCode: 503, Body: "unavailable"}}, Mode: SequenceFallThrough}})
+	filter.Add(Expectation{Key: "ok", Priority: 1, Response: &ExpectationResponse{HTTPCode: 200, Body: "ok"}})
+
+	// Act
+	first := filter.Apply(httptest.NewRequest("GET", "/", nil))
+	second := filter.Apply(httptest.NewRequest("GET", "/", nil))
+	found := filter.Reset("retry")
+	third := filter.Apply(httptest.NewRequest("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, 503, first.HTTPCode)
+	assert.Equal(t, "unavailable", string(first.Body))
+	assert.Equal(t, 200, second.HTTPCode)
+	assert.True(t, found)
+	assert.Equal(t, 503, third.HTTPCode)
+}
+
+func TestGzStorage_Reset(t *testing.T) {
+	storage := NewGzStorage()
+	seqA := &ResponseSequence{Responses: []ExpectationResponse{{}, {}}, Position: 1}
+	seqB := &ResponseSequence{Responses: []ExpectationResponse{{}, {}}, Position: 1}
+	storage.Add(Expectation{Key: "a", Sequence: seqA})
+	storage.Add(Expectation{Key: "b", Sequence: seqB})
+	storage.Add(Expectation{Key: "c", Response: &ExpectationResponse{}})
+
+	// Act
+	foundC := storage.Reset("c")
+	foundMissing := storage.Reset("missing")
+	foundA := storage.Reset("a")
+	positionB := seqB.Position
+	foundAll := storage.Reset("")
+
+	// Assert
+	assert.False(t, foundC)
+	assert.False(t, foundMissing)
+	assert.True(t, foundA)
+	assert.Equal(t, 0, seqA.Position)
+	assert.Equal(t, 1, positionB)
+	assert.True(t, foundAll)
+	assert.Equal(t, 0, seqB.Position)
+}
@@ -0,0 +1,132 @@
+package expectations
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// XPathMatcher is filter for XML (SOAP) request body.
+// Namespaces maps prefixes used in expressions to namespace URIs
+type XPathMatcher struct {
+	Namespaces  map[string]string `json:"namespaces,omitempty"`
+	Expressions []XPathExpression `json:"expressions"`
+}
+
+// XPathExpression is filter for value selected from XML request body by XPath expression.
+// If neither value nor regex is set, expression should select at least one node or evaluate to true.
+// Not requires none of selected values to pass the filter
+type XPathExpression struct {
+	Path  string `json:"path"`
+	Value string `json:"value,omitempty"`
+	Regex string `json:"regex,omitempty"`
+	Not   bool   `json:"not,omitempty"`
+
+	// regex and validation error of expression are set by compile.
+	// xpath.Expr changes its state while it's evaluated, so it's compiled for every request
+	compiled bool
+	regex    *regexp.Regexp
+	err      error
+}
+
+// compile compiles XPath expressions and regexes of all filters
+func (exp *XPathMatcher) compile() error {
+	var err error
+	for i := range exp.Expressions {
+		if exprErr := exp.Expressions[i].compile(exp.Namespaces); err == nil {
+			err = exprErr
+		}
+	}
+	return err
+}
+
+// compile validates XPath expression and compiles regex of the filter. Invalid filter never passes
+func (exp *XPathExpression) compile(namespaces map[string]string) error {
+	exp.compiled = true
+	_, exp.err = xpath.CompileWithNS(exp.Path, namespaces)
+	if exp.err == nil && len(exp.Regex) > 0 {
+		exp.regex, exp.err = regexp.Compile("(?s)" + exp.Regex)
+	}
+	return exp.err
+}
+
+// xpathEvaluate returns string values of the XPath expression result
+func xpathEvaluate(doc *xmlquery.Node, expr *xpath.Expr) []string {
+	switch result := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		var values []string
+		for result.MoveNext() {
+			values = append(values, strings.TrimSpace(result.Current().Value()))
+		}
+		return values
+	case bool:
+		if !result {
+			return nil
+		}
+		return []string{strconv.FormatBool(result)}
+	case float64:
+		return []string{strconv.FormatFloat(result, 'f', -1, 64)}
+	case string:
+		return []string{result}
+	}
+	return nil
+}
+
+// xpathValueMatch validates whether the selected value passes the filter
+func xpathValueMatch(value string, exp *XPathExpression) bool {
+	if len(exp.Value) > 0 && value != exp.Value {
+		return false
+	}
+
+	if len(exp.Regex) > 0 {
+		if !exp.regex.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// xpathsMatch validates whether the XML request body passes all XPath filters.
+// Returns path of the first failed filter
+func xpathsMatch(body string, exp *XPathMatcher) (string, bool) {
+	if exp == nil || len(exp.Expressions) == 0 {
+		return "", true
+	}
+
+	doc, err := xmlquery.Parse(strings.NewReader(body))
+	if err != nil {
+		return exp.Expressions[0].Path, false
+	}
+
+	for i := range exp.Expressions {
+		expr := &exp.Expressions[i]
+		if !expr.compiled {
+			compiled := *expr
+			compiled.compile(exp.Namespaces)
+			expr = &compiled
+		}
+		if expr.err != nil {
+			return expr.Path, false
+		}
+		compiledExpr, err := xpath.CompileWithNS(expr.Path, exp.Namespaces)
+		if err != nil {
+			return expr.Path, false
+		}
+		matched := false
+		for _, value := range xpathEvaluate(doc, compiledExpr) {
+			if xpathValueMatch(value, expr) {
+				matched = true
+				break
+			}
+		}
+		if matched == expr.Not {
+			return expr.Path, false
+		}
+	}
+
+	return "", true
+}
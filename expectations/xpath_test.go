@@ -0,0 +1,146 @@
+package expectations
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const xpathTestBody = `<?xml version="1.0" encoding="UTF-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<m:GetPrice xmlns:m="http://supplier.com/prices">
+			<m:Item code="A1">Apples</m:Item>
+			<m:Item code="B2">Bananas</m:Item>
+		</m:GetPrice>
+	</soap:Body>
+</soap:Envelope>`
+
+var xpathTestNamespaces = map[string]string{
+	"s": "http://schemas.xmlsoap.org/soap/envelope/",
+	"p": "http://supplier.com/prices",
+}
+
+func TestXPathsMatch_NilFilter_True(t *testing.T) {
+	_, ok := xpathsMatch("not xml", nil)
+
+	assert.True(t, ok)
+}
+
+func TestXPathsMatch_NamespacedValue_True(t *testing.T) {
+	_, ok := xpathsMatch(xpathTestBody, &XPathMatcher{
+		Namespaces: xpathTestNamespaces,
+		Expressions: []XPathExpression{
+			{Path: "/s:Envelope/s:Body/p:GetPrice/p:Item[@code='B2']", Value: "Bananas"},
+		},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestXPathsMatch_DifferentPrefixInRequest_True(t *testing.T) {
+	body := `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Body/></env:Envelope>`
+
+	_, ok := xpathsMatch(body, &XPathMatcher{
+		Namespaces:  xpathTestNamespaces,
+		Expressions: []XPathExpression{{Path: "/s:Envelope/s:Body"}},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestXPathsMatch_AttributeRegex_True(t *testing.T) {
+	_, ok := xpathsMatch(xpathTestBody, &XPathMatcher{
+		Namespaces:  xpathTestNamespaces,
+		Expressions: []XPathExpression{{Path: "//p:Item/@code", Regex: "^B\\d$"}},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestXPathsMatch_FunctionResult_True(t *testing.T) {
+	_, ok := xpathsMatch(xpathTestBody, &XPathMatcher{
+		Namespaces: xpathTestNamespaces,
+		Expressions: []XPathExpression{
+			{Path: "count(//p:Item)", Value: "2"},
+			{Path: "boolean(//p:Item[text()='Apples'])"},
+		},
+	})
+
+	assert.True(t, ok)
+}
+
+func TestXPathsMatch_FailedPathReported(t *testing.T) {
+	path, ok := xpathsMatch(xpathTestBody, &XPathMatcher{
+		Namespaces: xpathTestNamespaces,
+		Expressions: []XPathExpression{
+			{Path: "//p:Item[1]", Value: "Apples"},
+			{Path: "//p:Item[2]", Value: "Cherries"},
+		},
+	})
+
+	assert.False(t, ok)
+	assert.Equal(t, "//p:Item[2]", path)
+}
+
+func TestXPathsMatch_NotXMLBody_False(t *testing.T) {
+	_, ok := xpathsMatch("<a>", &XPathMatcher{Expressions: []XPathExpression{{Path: "/a"}}})
+
+	assert.False(t, ok)
+}
+
+func TestExpectationsMatch_XPath_True(t *testing.T) {
+	assert.True(t, expectationsMatch(
+		&IncomingRequest{Body: xpathTestBody},
+		&ExpectationRequest{XPath: &XPathMatcher{
+			Namespaces:  xpathTestNamespaces,
+			Expressions: []XPathExpression{{Path: "//p:Item", Value: "Apples"}},
+		}}))
+}
+
+func TestXPathsMatch_Not(t *testing.T) {
+	exp := &XPathMatcher{
+		Namespaces:  xpathTestNamespaces,
+		Expressions: []XPathExpression{{Path: "//p:Item", Value: "Cherries", Not: true}},
+	}
+
+	_, ok := xpathsMatch(xpathTestBody, exp)
+	assert.True(t, ok)
+
+	exp.Expressions[0].Value = "Apples"
+	_, ok = xpathsMatch(xpathTestBody, exp)
+	assert.False(t, ok)
+}
+
+func TestGzFilter_ApplyXPath_Concurrent(t *testing.T) {
+	filter := NewMockedGzFilter()
+	filter.Add(Expectation{
+		Key: "k",
+		Request: &ExpectationRequest{XPath: &XPathMatcher{
+			Namespaces: xpathTestNamespaces,
+			Expressions: []XPathExpression{
+				{Path: "//s:Body//p:Item", Value: "Bananas"},
+				{Path: "count(//p:Item)", Value: "2"},
+			},
+		}},
+		Response: &ExpectationResponse{HTTPCode: http.StatusOK, Body: "ok"},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Act
+			resp := filter.Apply(httpNewRequestMust("POST", "/soap", strings.NewReader(xpathTestBody)))
+
+			// Assert
+			assert.Equal(t, http.StatusOK, resp.HTTPCode)
+		}()
+	}
+	wg.Wait()
+}
@@ -1,6 +1,11 @@
 module github.com/Travix-International/gozzmock
 
+go 1.16
+
 require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/antchfx/xmlquery v1.3.17
+	github.com/antchfx/xpath v1.2.4
 	github.com/beorn7/perks v1.0.0 // indirect
 	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
 	github.com/golang/protobuf v1.3.1 // indirect
@@ -15,6 +20,7 @@ require (
 	github.com/uber-go/atomic v1.4.0 // indirect
 	github.com/uber/jaeger-client-go v2.16.0+incompatible
 	github.com/uber/jaeger-lib v2.0.0+incompatible // indirect
+	github.com/xeipuuv/gojsonschema v1.2.0
 	go.uber.org/atomic v1.4.0 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
 )
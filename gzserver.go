@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -79,6 +80,32 @@ func (s *gzServer) remove(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Expectation with key '%s' was removed", expRemove.Key)
 }
 
+// HandlerResetSequence handler parses request and moves response sequence of expectation to the first response
+func (s *gzServer) reset(w http.ResponseWriter, r *http.Request) {
+	fLog := log.With().Str("messagetype", "HandlerResetSequence").Logger()
+
+	span := getSpanWithContextFromRequest(r)
+	defer span.Finish()
+
+	if r.Method != "POST" {
+		fLog.Panic().Msgf("Wrong method %s", r.Method)
+		reportError(w)
+		return
+	}
+	expReset, err := expectations.HttpRequestToExpectationReset(r)
+	if err != nil {
+		fLog.Panic().Err(err).Msg("")
+		reportError(w)
+		return
+	}
+
+	if !s.filter.Reset(expReset.Key) {
+		http.Error(w, fmt.Sprintf("No response sequence with key '%s'", expReset.Key), http.StatusNotFound)
+		return
+	}
+	fmt.Fprintf(w, "Response sequence with key '%s' was reset", expReset.Key)
+}
+
 // HandlerGetExpectations handler parses request and returns global expectations list
 func (s *gzServer) get(w http.ResponseWriter, r *http.Request) {
 	fLog := log.With().Str("messagetype", "HandlerGetExpectations").Logger()
@@ -119,11 +146,16 @@ func (s *gzServer) root(w http.ResponseWriter, r *http.Request) {
 
 	resp := s.filter.Apply(r)
 
-	for name, value := range resp.Headers {
-		w.Header().Set(name, value)
+	for name, values := range resp.Headers {
+		w.Header().Del(name)
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
 	}
 	w.WriteHeader(resp.HTTPCode)
-	w.Write(resp.Body)
+	if err := resp.WriteBody(w); err != nil {
+		log.Error().Str("messagetype", "HandlerRoot").Err(err).Msg("Error writing response body")
+	}
 }
 
 func (s *gzServer) handle(pattern string, handler func(http.ResponseWriter, *http.Request)) {
@@ -180,12 +212,25 @@ func getSpanWithContextFromRequest(r *http.Request) opentracing.Span {
 	return span
 }
 
-func (s *gzServer) serve(port string) {
+// serve listens HTTP or HTTPS if certificate and key files are set.
+// HTTPS server requests client certificate, but doesn't verify it, so expectations can match on it
+func (s *gzServer) serve(port string, certFile string, keyFile string) {
 	http.HandleFunc("/gozzmock/status", s.status)
 	http.Handle("/metrics", promhttp.Handler())
 	s.handle("/gozzmock/add_expectation", s.add)
 	s.handle("/gozzmock/remove_expectation", s.remove)
 	s.handle("/gozzmock/get_expectations", s.get)
+	s.handle("/gozzmock/reset_sequence", s.reset)
 	s.handle("/", s.root)
-	http.ListenAndServe(":"+port, nil)
+
+	if len(certFile) == 0 || len(keyFile) == 0 {
+		http.ListenAndServe(":"+port, nil)
+		return
+	}
+
+	server := &http.Server{
+		Addr:      ":" + port,
+		TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+	}
+	server.ListenAndServeTLS(certFile, keyFile)
 }
@@ -10,6 +10,8 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -119,7 +121,7 @@ func TestHandlerRoot_TwoOverlapingExpectations(t *testing.T) {
 	server := newMockedGzServer()
 	exp1 := expectations.Expectation{
 		Key:      "response",
-		Request:  &expectations.ExpectationRequest{Path: "/response"},
+		Request:  &expectations.ExpectationRequest{Path: expectations.StringMatcher{Value: "/response"}},
 		Response: &expectations.ExpectationResponse{HTTPCode: http.StatusOK, Body: "response body"},
 		Priority: 1}
 
@@ -153,7 +155,7 @@ func TestHandlerGet_GetExpectations(t *testing.T) {
 	server := newMockedGzServer()
 	exp1 := expectations.Expectation{
 		Key:      "response",
-		Request:  &expectations.ExpectationRequest{Path: "/response"},
+		Request:  &expectations.ExpectationRequest{Path: expectations.StringMatcher{Value: "/response"}},
 		Response: &expectations.ExpectationResponse{HTTPCode: http.StatusOK, Body: "response body"},
 		Priority: 1}
 
@@ -176,7 +178,7 @@ func TestHandler_Status(t *testing.T) {
 	server := newMockedGzServer()
 	exp1 := expectations.Expectation{
 		Key:      "response",
-		Request:  &expectations.ExpectationRequest{Path: "/response"},
+		Request:  &expectations.ExpectationRequest{Path: expectations.StringMatcher{Value: "/response"}},
 		Response: &expectations.ExpectationResponse{HTTPCode: http.StatusOK, Body: "response body"},
 		Priority: 1}
 
@@ -200,7 +202,7 @@ func TestHandlerRoot_ForwardValidatrHeaders(t *testing.T) {
 		Forward: &expectations.ExpectationForward{
 			Scheme:  "https",
 			Host:    "local.xx",
-			Headers: expectations.Headers{"Host": "fwd_host"}},
+			Headers: expectations.Headers{"Host": {"fwd_host"}}},
 		Priority: 0}
 
 	server.filter.Add(exp1)
@@ -297,3 +299,138 @@ func TestHandlerRoot_RespondsWithJsTemplate(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "3", w.Body.String())
 }
+
+type mockedCookiesRoundTripper struct{}
+
+func (rt *mockedCookiesRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	resp.Header.Add("Set-Cookie", "a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT")
+	resp.Header.Add("Set-Cookie", "b=2")
+	resp.Body = ioutil.NopCloser(strings.NewReader(strings.Join(req.Header["Accept"], "|")))
+	return &resp, nil
+}
+
+func TestHandlerRoot_ForwardKeepsMultipleHeaderValues(t *testing.T) {
+	server := &gzServer{logLevel: zerolog.DebugLevel}
+	server.filter = expectations.NewGzFilter(&mockedCookiesRoundTripper{}, expectations.NewGzStorage())
+	server.filter.Add(expectations.Expectation{
+		Key:     "forward",
+		Forward: &expectations.ExpectationForward{Scheme: "https", Host: "local.xx"}})
+
+	r := httpNewRequestMust("GET", "/forward", nil)
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	// Act
+	server.root(w, r)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html|application/json", w.Body.String())
+	assert.Equal(t, []string{"a=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT", "b=2"}, w.Header()["Set-Cookie"])
+}
+
+func TestHandlerRoot_ResponseWithMultipleHeaderValues(t *testing.T) {
+	server := newMockedGzServer()
+
+	expJSON := `{"key": "k", "response": {"httpcode": 200, "body": "b",
+		"headers": {"Content-Type": "text/plain", "Link": ["<a>; rel=next", "<b>; rel=last"]}}}`
+	server.add(httptest.NewRecorder(), httpNewRequestMust("POST", "/add", strings.NewReader(expJSON)))
+
+	w := httptest.NewRecorder()
+
+	// Act
+	server.root(w, httpNewRequestMust("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"<a>; rel=next", "<b>; rel=last"}, w.Header()["Link"])
+}
+
+func TestHandlerRoot_VirtualHosts(t *testing.T) {
+	server := newMockedGzServer()
+	for _, host := range []string{"api.supplier-a.com", "api.supplier-b.com"} {
+		server.filter.Add(expectations.Expectation{
+			Key:      host,
+			Request:  &expectations.ExpectationRequest{Host: expectations.StringMatcher{Equals: host}},
+			Response: &expectations.ExpectationResponse{HTTPCode: http.StatusOK, Body: "response from " + host}})
+	}
+
+	rA := httpNewRequestMust("GET", "/price", nil)
+	rA.Host = "api.supplier-a.com"
+	rB := httpNewRequestMust("GET", "/price", nil)
+	rB.Host = "api.supplier-b.com:8080"
+	wA := httptest.NewRecorder()
+	wB := httptest.NewRecorder()
+
+	// Act
+	server.root(wA, rA)
+	server.root(wB, rB)
+
+	// Assert
+	assert.Equal(t, "response from api.supplier-a.com", wA.Body.String())
+	assert.Equal(t, "response from api.supplier-b.com", wB.Body.String())
+}
+
+func TestHandlerRoot_RespondsWithBodyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gozzmock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	body := bytes.Repeat([]byte{0, 1, 2, 3}, 1<<18)
+	if err := ioutil.WriteFile(filepath.Join(dir, "payload"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	previous := expectations.MockDataDir
+	expectations.MockDataDir = dir
+	defer func() { expectations.MockDataDir = previous }()
+
+	server := newMockedGzServer()
+	expJSON := `{"key": "k", "response": {"httpcode": 200, "bodyFile": "payload"}}`
+	server.add(httptest.NewRecorder(), httpNewRequestMust("POST", "/add", strings.NewReader(expJSON)))
+
+	w := httptest.NewRecorder()
+
+	// Act
+	server.root(w, httpNewRequestMust("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1048576", w.Header().Get("Content-Length"))
+	assert.Equal(t, body, w.Body.Bytes())
+}
+
+func TestHandlerRoot_ResponseSequence(t *testing.T) {
+	server := newMockedGzServer()
+
+	expJSON := `{"key": "retry", "sequence": {"responses": [{"httpcode": 503}, {"httpcode": 200, "body": "ok"}]}}`
+	server.add(httptest.NewRecorder(), httpNewRequestMust("POST", "/add", strings.NewReader(expJSON)))
+
+	w1 := httptest.NewRecorder()
+	w2 := httptest.NewRecorder()
+	wGet := httptest.NewRecorder()
+	wReset := httptest.NewRecorder()
+	wResetMissing := httptest.NewRecorder()
+	w3 := httptest.NewRecorder()
+
+	// Act
+	server.root(w1, httpNewRequestMust("GET", "/", nil))
+	server.root(w2, httpNewRequestMust("GET", "/", nil))
+	server.get(wGet, httpNewRequestMust("GET", "/gozzmock/get_expectations", nil))
+	server.reset(wReset, httpNewRequestMust("POST", "/reset", strings.NewReader(`{"key": "retry"}`)))
+	server.reset(wResetMissing, httpNewRequestMust("POST", "/reset", strings.NewReader(`{"key": "missing"}`)))
+	server.root(w3, httpNewRequestMust("GET", "/", nil))
+
+	// Assert
+	assert.Equal(t, http.StatusServiceUnavailable, w1.Code)
+	assert.Equal(t, http.StatusOK, w2.Code)
+	assert.Equal(t, "ok", w2.Body.String())
+	assert.Contains(t, wGet.Body.String(), `"position":1`)
+	assert.Equal(t, "Response sequence with key 'retry' was reset", wReset.Body.String())
+	assert.Equal(t, http.StatusNotFound, wResetMissing.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, w3.Code)
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/Travix-International/gozzmock/expectations"
 	"github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
 )
@@ -22,6 +25,30 @@ func main() {
 		logLevel = "debug"
 	}
 
+	// set time limit of JS predicates, e.g. 100ms
+	jsTimeout := os.Getenv("GOZ_JS_TIMEOUT")
+	if len(jsTimeout) > 0 {
+		timeout, err := time.ParseDuration(jsTimeout)
+		if err != nil {
+			panic(err)
+		}
+		expectations.JsPredicateTimeout = timeout
+	}
+
+	// set directory with files of response bodies
+	dataDir := os.Getenv("GOZ_DATA_DIR")
+	if len(dataDir) > 0 {
+		expectations.MockDataDir = dataDir
+	}
+
+	// set comma separated networks of proxies which X-Forwarded-For and X-Forwarded-Proto are trusted from
+	trustedProxies := os.Getenv("GOZ_TRUSTED_PROXIES")
+	if len(trustedProxies) > 0 {
+		if err := expectations.SetTrustedProxies(strings.Split(trustedProxies, ",")); err != nil {
+			panic(err)
+		}
+	}
+
 	closer := initJaeger()
 	defer closer.Close()
 
@@ -31,11 +58,19 @@ func main() {
 		port = "8080"
 	}
 
+	// set certificate and key files to serve HTTPS
+	tlsCert := os.Getenv("GOZ_TLS_CERT")
+	tlsKey := os.Getenv("GOZ_TLS_KEY")
+
 	fmt.Println("Arguments:")
 	fmt.Println("initial expectations:", initExpectations)
 	fmt.Println("initial expectations from json file:", initExpectationJSONFile)
 	fmt.Println("loglevel:", logLevel)
 	fmt.Println("port:", port)
+	fmt.Println("TLS certificate:", tlsCert)
+	fmt.Println("JS predicate timeout:", expectations.JsPredicateTimeout)
+	fmt.Println("mock data directory:", expectations.MockDataDir)
+	fmt.Println("trusted proxies:", trustedProxies)
 
 	server := newGzServer(logLevel)
 	if len(initExpectations) > 2 {
@@ -51,7 +86,7 @@ func main() {
 		}
 	}
 
-	server.serve(port)
+	server.serve(port, tlsCert, tlsKey)
 }
 
 // initJaeger returns an instance of Jaeger Tracer that can be configured with environment variables